@@ -3,8 +3,10 @@ package main
 import (
 	"fmt"
 	"log"
+	"time"
 
 	"app/internal/app/handler"
+	"app/internal/app/model"
 	"app/internal/app/service"
 
 	"github.com/gin-gonic/gin"
@@ -23,6 +25,38 @@ type Config struct {
 	DBHost       string
 	DBPort       string
 	DBName       string
+	// VerificationStore 验证码存储方式：database（默认，多实例共享）或 memory
+	VerificationStore string
+	// MailBackend 邮件发送方式：smtp（默认）、file（写入 MailDir 目录的 .eml 文件）或 memory
+	MailBackend string
+	MailDir     string
+	MailFrom    string // 发件人，为空时使用 SmtpUsername
+	// MailTemplateDir 邮件模板目录，默认是 config.json 旁的 mail_templates，其中的同名文件覆盖内置模板
+	MailTemplateDir string
+	// RateLimit 验证码和登录接口的限流参数，未配置的项使用默认值
+	RateLimit RateLimitConfig
+	// AdminEmails 启动时把这些邮箱对应的用户设为管理员
+	AdminEmails []string
+	// OIDCProviders 第三方登录配置，本地开发可以使用 cmd/testidp
+	OIDCProviders []services.OIDCProviderConfig
+	// Streak 连续打卡保护卡和补打卡的价格与期限，未配置的项使用默认值
+	Streak services.StreakConfig
+}
+
+// RateLimitConfig 限流配置，时间单位均为秒
+type RateLimitConfig struct {
+	IPRequests             int
+	IPWindowSeconds        int
+	EmailRequests          int
+	EmailWindowSeconds     int
+	ResendCooldownSeconds  int
+	MaxFailedVerifications int
+	LockoutSeconds         int
+}
+
+// seconds 把配置中的秒数转换为 time.Duration
+func seconds(n int) time.Duration {
+	return time.Duration(n) * time.Second
 }
 
 func initConfig() *Config {
@@ -39,13 +73,34 @@ func initConfig() *Config {
 	return &config
 }
 
+// newMailer 根据配置创建邮件发送器
+func newMailer(config *Config) services.Mailer {
+	from := config.MailFrom
+	if from == "" {
+		from = config.SmtpUsername
+	}
+
+	switch config.MailBackend {
+	case "file":
+		mailer, err := services.NewFileMailer(config.MailDir, from)
+		if err != nil {
+			log.Fatalf("Unable to create mail directory, %v \n", err)
+		}
+		return mailer
+	case "memory":
+		return services.NewMemoryMailer()
+	default:
+		return services.NewSMTPMailer(config.SmtpServer, config.SmtpPort, config.SmtpUsername, config.SmtpPassword, from)
+	}
+}
+
 // CORS中间件
 func CORSMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		c.Writer.Header().Set("Access-Control-Allow-Origin", "*")
 		c.Writer.Header().Set("Access-Control-Allow-Credentials", "true")
 		c.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization, accept, origin, Cache-Control, X-Requested-With")
-		c.Writer.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS, GET, PUT, DELETE")
+		c.Writer.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS, GET, PUT, PATCH, DELETE")
 
 		if c.Request.Method == "OPTIONS" {
 			c.AbortWithStatus(204)
@@ -56,36 +111,140 @@ func CORSMiddleware() gin.HandlerFunc {
 	}
 }
 
-func setupRouter(authService *services.AuthService, taskService *services.TaskService) *gin.Engine {
+func setupRouter(authService *services.AuthService, taskService *services.TaskService, ipLimiter *services.RateLimiter) *gin.Engine {
 	r := gin.Default()
 
 	// 应用CORS中间件
 	r.Use(CORSMiddleware())
 
-	// 用户认证和管理路由
-	r.POST("/users/:userID/upgrade", handlers.UpgradeUserHandler(authService))
-	r.GET("/dailyTasks", handlers.GetDailyTaskHandler(authService))
-	r.GET("/users/:userID/dailyTasks", handlers.GetDailyTaskByUserIDHandler(authService))
-	r.POST("/users/:userID/convertPoints", handlers.ConvertPointsToExperienceHandler(authService))
-	r.POST("/forgot_password", handlers.ForgotPasswordHandler)
-	r.POST("/reset_password", handlers.ResetPasswordHandler)
-	r.POST("/login", handlers.LoginHandler)
-	r.POST("/register", handlers.RegisterHandler)
-	r.POST("/verify_code", handlers.VerifyCodeHandler)
+	// 登录注册等无需认证的路由，发送和校验验证码的接口按 IP 限流
+	limited := r.Group("/", handlers.RateLimitByIP(ipLimiter))
+	limited.POST("/forgot_password", handlers.ForgotPasswordHandler(authService))
+	limited.POST("/reset_password", handlers.ResetPasswordHandler(authService))
+	limited.POST("/login", handlers.LoginHandler(authService))
+	limited.POST("/login/code", handlers.SendLoginCodeHandler(authService))
+	limited.POST("/login/2fa", handlers.LoginTOTPHandler(authService))
+	limited.POST("/register", handlers.RegisterHandler(authService))
+	limited.POST("/verify_code", handlers.VerifyCodeHandler(authService))
+	limited.GET("/oidc/:provider/login", handlers.OIDCLoginHandler(authService))
+	limited.GET("/oidc/:provider/callback", handlers.OIDCCallbackHandler(authService))
+	r.GET("/oidc/providers", handlers.ListOIDCProvidersHandler(authService))
+	r.POST("/token/refresh", handlers.RefreshTokenHandler(authService))
+	r.POST("/logout", handlers.LogoutHandler(authService))
+
+	// 以下路由都需要登录，当前用户由 AuthMiddleware 放入上下文
+	authorized := r.Group("/", handlers.AuthMiddleware(authService))
+
+	// 任务和每日任务接口还接受个人 API key，供脚本调用
+	scripted := r.Group("/", handlers.APIKeyAuthMiddleware(authService))
+	readTasks := handlers.RequireScope(services.ScopeReadOnly)
+	writeTasks := handlers.RequireScope(services.ScopeTasksWrite)
+
+	// 用户管理路由，/me 是 /users/:userID 的别名
+	for _, users := range []*gin.RouterGroup{
+		authorized.Group("/users/:userID", handlers.RequireSelf()),
+		authorized.Group("/me"),
+	} {
+		users.POST("/upgrade", handlers.UpgradeUserHandler(authService))
+		users.POST("/convertPoints", handlers.ConvertPointsToExperienceHandler(authService))
+	}
+	for _, users := range []*gin.RouterGroup{
+		scripted.Group("/users/:userID", handlers.RequireSelf()),
+		scripted.Group("/me"),
+	} {
+		users.GET("/dailyTasks", readTasks, handlers.GetDailyTaskByUserIDHandler(authService))
+	}
+	scripted.GET("/dailyTasks", readTasks, handlers.GetDailyTaskHandler(authService))
+
+	// 个人资料路由
+	authorized.GET("/me", handlers.GetProfileHandler(authService))
+	authorized.PATCH("/me", handlers.UpdateProfileHandler(authService))
+	authorized.POST("/me/password", handlers.ChangePasswordHandler(authService))
+	authorized.POST("/me/email", handlers.ChangeEmailHandler(authService))
+	authorized.GET("/me/export", handlers.ExportAccountHandler(authService))
+	authorized.DELETE("/me", handlers.DeleteAccountHandler(authService))
+	authorized.POST("/me/cancel_deletion", handlers.CancelAccountDeletionHandler(authService))
+
+	// 登录设备管理
+	authorized.GET("/me/sessions", handlers.ListSessionsHandler(authService))
+	authorized.DELETE("/me/sessions", handlers.RevokeAllSessionsHandler(authService))
+	authorized.DELETE("/me/sessions/:id", handlers.RevokeSessionHandler(authService))
+
+	// 个人 API key 管理
+	authorized.GET("/me/api_keys", handlers.ListAPIKeysHandler(authService))
+	authorized.POST("/me/api_keys", handlers.CreateAPIKeyHandler(authService))
+	authorized.DELETE("/me/api_keys/:id", handlers.RevokeAPIKeyHandler(authService))
+
+	// 第三方登录身份
+	authorized.GET("/me/identities", handlers.ListIdentitiesHandler(authService))
+	authorized.POST("/me/identities/:provider", handlers.LinkIdentityHandler(authService))
+	authorized.DELETE("/me/identities/:id", handlers.UnlinkIdentityHandler(authService))
+
+	// 两步验证路由
+	authorized.POST("/me/2fa/setup", handlers.SetupTOTPHandler(authService))
+	authorized.POST("/me/2fa/confirm", handlers.ConfirmTOTPHandler(authService))
+	authorized.POST("/me/2fa/disable", handlers.DisableTOTPHandler(authService))
+
+	// 站内通知
+	scripted.GET("/me/notifications", readTasks, handlers.ListNotificationsHandler(authService))
+	scripted.POST("/me/notifications/read_all", writeTasks, handlers.MarkAllNotificationsReadHandler(authService))
+	scripted.POST("/me/notifications/:id/read", writeTasks, handlers.MarkNotificationReadHandler(authService))
 
 	// 任务相关路由
-	r.POST("/task", handlers.CreateTaskHandler(taskService))
-	r.GET("/task/:id", handlers.GetTaskHandler(taskService))
-	r.GET("/daily_task/:userID", handlers.GetRandomDailyTaskHandler)
-	r.POST("/mark_completed/:taskID", handlers.MarkTaskCompletedHandler)
-	r.GET("/random_adventure", handlers.GetRandomAdventureTaskHandler)
-	r.POST("/create_combination", handlers.CreateCombinationTaskHandler)
-	r.DELETE("/delete_completed_tasks", handlers.DeleteCompletedTasksHandler)
-	r.POST("/complete_team_task/:id", handlers.CompleteTeamTaskHandler)
+	scripted.POST("/task", writeTasks, handlers.CreateTaskHandler(taskService))
+	scripted.GET("/task/:id", readTasks, handlers.GetTaskHandler(taskService))
+	scripted.GET("/daily_task/:userID", readTasks, handlers.RequireSelf(), handlers.GetRandomDailyTaskHandler(taskService))
+	scripted.GET("/me/daily_task", readTasks, handlers.GetRandomDailyTaskHandler(taskService))
+	scripted.POST("/mark_completed/:taskID", writeTasks, handlers.MarkTaskCompletedHandler(taskService))
+	scripted.PATCH("/tasks/:id", writeTasks, handlers.UpdateTaskHandler(taskService))
+	scripted.DELETE("/tasks/:id", writeTasks, handlers.DeleteTaskHandler(taskService))
+	scripted.GET("/tasks/:id/subtasks", readTasks, handlers.ListSubTasksHandler(taskService))
+	scripted.PUT("/tasks/:id/subtasks/order", writeTasks, handlers.ReorderSubTasksHandler(taskService))
+	scripted.PATCH("/subtasks/:id", writeTasks, handlers.UpdateSubTaskHandler(taskService))
+	scripted.POST("/subtasks/:id/complete", writeTasks, handlers.CompleteSubTaskHandler(taskService))
+	scripted.GET("/users/:userID/tasks", readTasks, handlers.RequireSelf(), handlers.ListUserTasksHandler(taskService))
+	scripted.GET("/me/tasks", readTasks, handlers.ListUserTasksHandler(taskService))
+	scripted.GET("/me/tasks/today", readTasks, handlers.ListDueTasksHandler(taskService, services.DueToday))
+	scripted.GET("/me/tasks/upcoming", readTasks, handlers.ListDueTasksHandler(taskService, services.DueUpcoming))
+	scripted.GET("/me/tasks/overdue", readTasks, handlers.ListDueTasksHandler(taskService, services.DueOverdue))
+	scripted.GET("/teams/:id/tasks", readTasks, handlers.ListTeamTasksHandler(taskService))
+	scripted.GET("/recurring_tasks", readTasks, handlers.ListRecurringTasksHandler(taskService))
+	scripted.POST("/recurring_tasks", writeTasks, handlers.CreateRecurringTaskHandler(taskService))
+	scripted.PATCH("/recurring_tasks/:id", writeTasks, handlers.UpdateRecurringTaskHandler(taskService))
+	scripted.DELETE("/recurring_tasks/:id", writeTasks, handlers.DeleteRecurringTaskHandler(taskService))
+	scripted.GET("/recurring_tasks/:id/calendar", readTasks, handlers.HabitCalendarHandler(taskService))
+	scripted.GET("/me/streaks", readTasks, handlers.ListStreaksHandler(taskService))
+	scripted.GET("/me/streak_freezes", readTasks, handlers.GetStreakItemsHandler(taskService))
+	scripted.POST("/me/streak_freezes", writeTasks, handlers.BuyStreakFreezesHandler(taskService))
+	scripted.POST("/recurring_tasks/:id/freeze", writeTasks, handlers.UseStreakFreezeHandler(taskService))
+	scripted.POST("/recurring_tasks/:id/make_up", writeTasks, handlers.MakeUpCheckInHandler(taskService))
+	authorized.GET("/random_adventure", handlers.GetRandomAdventureTaskHandler(taskService))
+	authorized.POST("/create_combination", handlers.CreateCombinationTaskHandler(taskService))
+	authorized.DELETE("/delete_completed_tasks", handlers.RequirePermission(services.PermDeleteCompletedTasks),
+		handlers.DeleteCompletedTasksHandler(taskService))
+	authorized.POST("/complete_team_task/:id", handlers.CompleteTeamTaskHandler(taskService))
 
 	// 团队相关路由
-	r.POST("/create_team", handlers.CreateTeamHandler)
-	r.POST("/join_team", handlers.JoinTeamHandler)
+	authorized.POST("/create_team", handlers.CreateTeamHandler)
+	authorized.POST("/join_team", handlers.JoinTeamHandler)
+
+	// 管理接口，按角色权限控制
+	admin := authorized.Group("/admin")
+	manageAdventures := handlers.RequirePermission(services.PermManageAdventures)
+	admin.GET("/adventures", manageAdventures, handlers.ListAdventureTasksHandler(taskService))
+	admin.POST("/adventures", manageAdventures, handlers.CreateAdventureTaskHandler(taskService))
+	admin.PATCH("/adventures/:id", manageAdventures, handlers.UpdateAdventureTaskHandler(taskService))
+	admin.DELETE("/adventures/:id", manageAdventures, handlers.DeleteAdventureTaskHandler(taskService))
+	admin.GET("/users", handlers.RequirePermission(services.PermViewUsers), handlers.SearchUsersHandler(authService))
+	admin.GET("/users/:id", handlers.RequirePermission(services.PermViewUsers), handlers.GetUserHandler(authService))
+	admin.POST("/users/:id/ban", handlers.RequirePermission(services.PermBanUsers), handlers.BanUserHandler(authService))
+	admin.DELETE("/users/:id/ban", handlers.RequirePermission(services.PermBanUsers), handlers.UnbanUserHandler(authService))
+	admin.POST("/users/:id/suspend", handlers.RequirePermission(services.PermSuspendUsers), handlers.SuspendUserHandler(authService))
+	admin.POST("/users/:id/unsuspend", handlers.RequirePermission(services.PermSuspendUsers), handlers.UnsuspendUserHandler(authService))
+	admin.POST("/users/:id/password", handlers.RequirePermission(services.PermManageUsers), handlers.AdminResetPasswordHandler(authService))
+	admin.POST("/users/:id/experience", handlers.RequirePermission(services.PermManageUsers), handlers.AdjustExperienceHandler(authService))
+	admin.PUT("/users/:id/role", handlers.RequirePermission(services.PermManageRoles), handlers.SetUserRoleHandler(authService))
+	admin.GET("/audit_logs", handlers.RequirePermission(services.PermViewAuditLogs), handlers.ListAuditLogsHandler(authService))
 
 	return r
 }
@@ -97,14 +256,101 @@ func main() {
 	dsn := fmt.Sprintf("%s:%s@tcp(%s:%s)/%s?charset=utf8mb4&parseTime=True&loc=Local",
 		config.DBUsername, config.DBPassword, config.DBHost, config.DBPort, config.DBName)
 
-	db, err := gorm.Open(mysql.Open(dsn), &gorm.Config{})
+	// TranslateError 把唯一索引冲突转换为 gorm.ErrDuplicatedKey
+	db, err := gorm.Open(mysql.Open(dsn), &gorm.Config{TranslateError: true})
 	if err != nil {
 		log.Fatal("Failed to connect to database:", err)
 	}
 
-	authService := services.NewAuthService(db)
-	taskService := services.NewTaskService(db)
+	// 自动迁移新增的数据表，以及用户表新增的字段
+	if err := db.AutoMigrate(&models.User{}, &models.Session{}, &models.VerificationCode{}, &models.PendingSignup{},
+		&models.RecoveryCode{}, &models.MFAChallenge{}, &models.APIKey{}, &models.AdventureTask{},
+		&models.AuditLog{}, &models.ExperienceLog{}, &models.OIDCIdentity{}, &models.OIDCLoginState{},
+		&models.Task{}, &models.SubTask{}, &models.TaskTag{}, &models.RecurringTask{},
+		&models.CheckIn{}, &models.TaskReminder{}, &models.Notification{}); err != nil {
+		log.Fatal("Failed to migrate database:", err)
+	}
+
+	var codes services.VerificationStore
+	if config.VerificationStore == "memory" {
+		codes = services.NewMemoryVerificationStore(services.MaxVerificationAttempts)
+	} else {
+		codes = services.NewDBVerificationStore(db, services.MaxVerificationAttempts)
+	}
+
+	templateDir := config.MailTemplateDir
+	if templateDir == "" {
+		templateDir = "mail_templates"
+	}
+	templates, err := services.LoadMailTemplates(templateDir)
+	if err != nil {
+		log.Fatal("Failed to load mail templates:", err)
+	}
+
+	limits, ipLimiter := services.NewAuthLimits(services.RateLimitConfig{
+		IPRequests:             config.RateLimit.IPRequests,
+		IPWindow:               seconds(config.RateLimit.IPWindowSeconds),
+		EmailRequests:          config.RateLimit.EmailRequests,
+		EmailWindow:            seconds(config.RateLimit.EmailWindowSeconds),
+		ResendCooldown:         seconds(config.RateLimit.ResendCooldownSeconds),
+		MaxFailedVerifications: config.RateLimit.MaxFailedVerifications,
+		LockoutDuration:        seconds(config.RateLimit.LockoutSeconds),
+	})
+
+	oidcProviders := make([]*services.OIDCProvider, 0, len(config.OIDCProviders))
+	for _, provider := range config.OIDCProviders {
+		oidcProviders = append(oidcProviders, services.NewOIDCProvider(provider))
+	}
+
+	authService := services.NewAuthService(db, codes, newMailer(config), templates, limits, oidcProviders)
+	taskService := services.NewTaskService(db, config.Streak)
+
+	if err := authService.PromoteAdmins(config.AdminEmails); err != nil {
+		log.Fatal("Failed to promote admins:", err)
+	}
+
+	// 按用户时区在每周固定时间发送任务周报
+	go func() {
+		if err := authService.SendWeeklyDigests(time.Now()); err != nil {
+			log.Println("Failed to send weekly digests:", err)
+		}
+		for now := range time.Tick(services.WeeklyDigestCheckInterval) {
+			if err := authService.SendWeeklyDigests(now); err != nil {
+				log.Println("Failed to send weekly digests:", err)
+			}
+		}
+	}()
+
+	// 按用户时区生成周期任务当天的实例
+	go func() {
+		if err := taskService.GenerateRecurringTasks(time.Now()); err != nil {
+			log.Println("Failed to generate recurring tasks:", err)
+		}
+		for now := range time.Tick(services.RecurringTaskInterval) {
+			if err := taskService.GenerateRecurringTasks(now); err != nil {
+				log.Println("Failed to generate recurring tasks:", err)
+			}
+		}
+	}()
+
+	// 发送任务的截止提醒和逾期通知
+	go func() {
+		for now := range time.Tick(services.TaskReminderInterval) {
+			if err := authService.SendTaskReminders(now); err != nil {
+				log.Println("Failed to send task reminders:", err)
+			}
+		}
+	}()
+
+	// 删除注销宽限期已过的账号数据
+	go func() {
+		for now := range time.Tick(services.AccountPurgeInterval) {
+			if err := authService.PurgeDeletedAccounts(now); err != nil {
+				log.Println("Failed to purge deleted accounts:", err)
+			}
+		}
+	}()
 
-	r := setupRouter(authService, taskService)
+	r := setupRouter(authService, taskService, ipLimiter)
 	r.Run(":8080") // 启动HTTP服务器
 }
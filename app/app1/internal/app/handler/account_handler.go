@@ -0,0 +1,125 @@
+package handlers
+
+import (
+	services "app/internal/app/service"
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ExportAccountHandler 导出当前用户的个人数据，默认返回 JSON，format=zip 时每类数据一个文件打包下载
+func ExportAccountHandler(authService *services.AuthService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		format := c.DefaultQuery("format", "json")
+		if format != "json" && format != "zip" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "format must be json or zip"})
+			return
+		}
+
+		export, err := authService.ExportAccount(CurrentUser(c).ID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to export data"})
+			return
+		}
+
+		now := time.Now()
+		files := []struct {
+			name string
+			data interface{}
+		}{
+			{"profile", newUserProfile(export.User)},
+			{"tasks", export.Tasks},
+			{"subtasks", export.SubTasks},
+			{"recurring_tasks", export.Recurring},
+			{"check_ins", export.CheckIns},
+			{"notifications", export.Notices},
+			{"teams", export.Teams},
+			{"comments", export.Comments},
+			{"experience_history", export.Experience},
+		}
+		filename := fmt.Sprintf("guide-export-%d-%s", export.User.ID, now.Format("20060102"))
+
+		if format == "json" {
+			bundle := gin.H{"exported_at": now}
+			for _, file := range files {
+				bundle[file.name] = file.data
+			}
+			c.Header("Content-Disposition", `attachment; filename="`+filename+`.json"`)
+			c.JSON(http.StatusOK, bundle)
+			return
+		}
+
+		var buf bytes.Buffer
+		archive := zip.NewWriter(&buf)
+		for _, file := range files {
+			w, err := archive.CreateHeader(&zip.FileHeader{Name: file.name + ".json", Method: zip.Deflate, Modified: now})
+			if err == nil {
+				encoder := json.NewEncoder(w)
+				encoder.SetIndent("", "  ")
+				err = encoder.Encode(file.data)
+			}
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to export data"})
+				return
+			}
+		}
+		if err := archive.Close(); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to export data"})
+			return
+		}
+
+		c.Header("Content-Disposition", `attachment; filename="`+filename+`.zip"`)
+		c.Data(http.StatusOK, "application/zip", buf.Bytes())
+	}
+}
+
+// DeleteAccountHandler 校验密码后申请注销账号，宽限期结束后删除数据
+func DeleteAccountHandler(authService *services.AuthService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req struct {
+			Password string `json:"password"`
+		}
+		if err := c.BindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request payload"})
+			return
+		}
+
+		user, err := authService.ScheduleAccountDeletion(CurrentUser(c).ID, req.Password)
+		if err != nil {
+			if errors.Is(err, services.ErrDeletionScheduled) {
+				c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			} else {
+				respondProfileError(c, err, "Failed to delete account")
+			}
+			return
+		}
+
+		c.JSON(http.StatusAccepted, gin.H{
+			"message":               "Account scheduled for deletion. Login and cancel before the date to keep it.",
+			"deletion_scheduled_at": user.DeletionScheduledAt,
+		})
+	}
+}
+
+// CancelAccountDeletionHandler 宽限期内撤销注销申请
+func CancelAccountDeletionHandler(authService *services.AuthService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user, err := authService.CancelAccountDeletion(CurrentUser(c).ID)
+		if err != nil {
+			if errors.Is(err, services.ErrDeletionNotScheduled) {
+				c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			} else {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to cancel account deletion"})
+			}
+			return
+		}
+
+		c.JSON(http.StatusOK, newUserProfile(user))
+	}
+}
@@ -0,0 +1,316 @@
+package handlers
+
+import (
+	models "app/internal/app/model"
+	services "app/internal/app/service"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// AdminUser 管理接口返回的用户信息，在资料之外包含封禁状态
+type AdminUser struct {
+	UserProfile
+	Banned      bool       `json:"banned"` // 当前是否处于封禁或暂停状态
+	BannedAt    *time.Time `json:"banned_at"`
+	BannedUntil *time.Time `json:"banned_until"` // 为空表示永久封禁
+	BanReason   string     `json:"ban_reason"`
+}
+
+func newAdminUser(user *models.User) AdminUser {
+	return AdminUser{
+		UserProfile: newUserProfile(user),
+		Banned:      services.IsUserBanned(user),
+		BannedAt:    user.BannedAt,
+		BannedUntil: user.BannedUntil,
+		BanReason:   user.BanReason,
+	}
+}
+
+// respondAdminError 把管理操作的错误转换为对应的状态码
+func respondAdminError(c *gin.Context, err error, message string) {
+	if abortIfRateLimited(c, err) {
+		return
+	}
+	switch {
+	case services.IsAdminError(err):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrOutranked), errors.Is(err, services.ErrRoleTooHigh):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrUserNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": message})
+	}
+}
+
+// userIDParam 解析路径中的用户ID
+func userIDParam(c *gin.Context) (uint, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return 0, false
+	}
+	return uint(id), true
+}
+
+// pageQuery 解析分页参数，未传时由服务层使用默认值
+func pageQuery(c *gin.Context) (int, int) {
+	page, _ := strconv.Atoi(c.Query("page"))
+	pageSize, _ := strconv.Atoi(c.Query("page_size"))
+	return page, pageSize
+}
+
+// SearchUsersHandler 按邮箱或用户名搜索用户
+func SearchUsersHandler(authService *services.AuthService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		page, pageSize := pageQuery(c)
+		users, total, err := authService.SearchUsers(c.Query("q"), page, pageSize)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to search users"})
+			return
+		}
+
+		result := make([]AdminUser, 0, len(users))
+		for i := range users {
+			result = append(result, newAdminUser(&users[i]))
+		}
+		c.JSON(http.StatusOK, gin.H{"users": result, "total": total})
+	}
+}
+
+// GetUserHandler 查看用户的资料、等级和四项经验
+func GetUserHandler(authService *services.AuthService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, ok := userIDParam(c)
+		if !ok {
+			return
+		}
+
+		user, err := authService.GetUser(userID)
+		if err != nil {
+			respondAdminError(c, err, "Failed to get user")
+			return
+		}
+		c.JSON(http.StatusOK, newAdminUser(user))
+	}
+}
+
+// SetUserRoleHandler 修改用户角色
+func SetUserRoleHandler(authService *services.AuthService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, ok := userIDParam(c)
+		if !ok {
+			return
+		}
+		var req struct {
+			Role   string `json:"role"`
+			Reason string `json:"reason"`
+		}
+		if err := c.BindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request payload"})
+			return
+		}
+
+		user, err := authService.SetUserRole(CurrentUser(c).ID, userID, req.Role, req.Reason)
+		if err != nil {
+			respondAdminError(c, err, "Failed to change role")
+			return
+		}
+
+		c.JSON(http.StatusOK, newAdminUser(user))
+	}
+}
+
+// reasonBody 解析可选的 {"reason": "..."} 请求体
+func reasonBody(c *gin.Context) (string, bool) {
+	var req struct {
+		Reason string `json:"reason"`
+	}
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request payload"})
+			return "", false
+		}
+	}
+	return req.Reason, true
+}
+
+// BanUserHandler 永久封禁用户，被封禁的用户立即退出所有设备
+func BanUserHandler(authService *services.AuthService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, ok := userIDParam(c)
+		if !ok {
+			return
+		}
+		reason, ok := reasonBody(c)
+		if !ok {
+			return
+		}
+
+		user, err := authService.BanUser(CurrentUser(c).ID, userID, reason)
+		if err != nil {
+			respondAdminError(c, err, "Failed to ban user")
+			return
+		}
+
+		c.JSON(http.StatusOK, newAdminUser(user))
+	}
+}
+
+// UnbanUserHandler 解除封禁或暂停
+func UnbanUserHandler(authService *services.AuthService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, ok := userIDParam(c)
+		if !ok {
+			return
+		}
+		reason, ok := reasonBody(c)
+		if !ok {
+			return
+		}
+
+		user, err := authService.UnbanUser(CurrentUser(c).ID, userID, reason)
+		if err != nil {
+			respondAdminError(c, err, "Failed to unban user")
+			return
+		}
+
+		c.JSON(http.StatusOK, newAdminUser(user))
+	}
+}
+
+// SuspendUserHandler 暂停用户若干天，到期自动恢复
+func SuspendUserHandler(authService *services.AuthService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, ok := userIDParam(c)
+		if !ok {
+			return
+		}
+		var req struct {
+			Reason string `json:"reason"`
+			Days   int    `json:"days"`
+		}
+		if err := c.BindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request payload"})
+			return
+		}
+
+		if req.Days < 1 || req.Days > services.MaxSuspensionDays {
+			c.JSON(http.StatusBadRequest, gin.H{"error": services.ErrInvalidSuspension.Error()})
+			return
+		}
+
+		until := time.Now().AddDate(0, 0, req.Days)
+		user, err := authService.SuspendUser(CurrentUser(c).ID, userID, req.Reason, until)
+		if err != nil {
+			respondAdminError(c, err, "Failed to suspend user")
+			return
+		}
+
+		c.JSON(http.StatusOK, newAdminUser(user))
+	}
+}
+
+// UnsuspendUserHandler 提前结束暂停
+func UnsuspendUserHandler(authService *services.AuthService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, ok := userIDParam(c)
+		if !ok {
+			return
+		}
+		reason, ok := reasonBody(c)
+		if !ok {
+			return
+		}
+
+		user, err := authService.UnsuspendUser(CurrentUser(c).ID, userID, reason)
+		if err != nil {
+			respondAdminError(c, err, "Failed to unsuspend user")
+			return
+		}
+
+		c.JSON(http.StatusOK, newAdminUser(user))
+	}
+}
+
+// AdminResetPasswordHandler 重置用户密码：带 password 时直接设置，否则向用户发送重置验证码
+func AdminResetPasswordHandler(authService *services.AuthService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, ok := userIDParam(c)
+		if !ok {
+			return
+		}
+		var req struct {
+			Password string `json:"password"`
+			Reason   string `json:"reason"`
+		}
+		if err := c.BindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request payload"})
+			return
+		}
+
+		if err := authService.AdminResetPassword(CurrentUser(c).ID, userID, req.Password, req.Reason); err != nil {
+			respondAdminError(c, err, "Failed to reset password")
+			return
+		}
+
+		if req.Password == "" {
+			c.JSON(http.StatusOK, gin.H{"message": "Password reset code sent to the user"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "Password reset successfully"})
+	}
+}
+
+// AdjustExperienceHandler 调整用户的某项经验，必须填写原因
+func AdjustExperienceHandler(authService *services.AuthService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, ok := userIDParam(c)
+		if !ok {
+			return
+		}
+		var req struct {
+			Track  string `json:"track"`
+			Delta  int    `json:"delta"`
+			Reason string `json:"reason"`
+		}
+		if err := c.BindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request payload"})
+			return
+		}
+
+		user, err := authService.AdjustExperience(CurrentUser(c).ID, userID, req.Track, req.Delta, req.Reason)
+		if err != nil {
+			respondAdminError(c, err, "Failed to adjust experience")
+			return
+		}
+
+		c.JSON(http.StatusOK, newAdminUser(user))
+	}
+}
+
+// ListAuditLogsHandler 查看审计日志，可按 user_id 筛选被操作的用户
+func ListAuditLogsHandler(authService *services.AuthService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var userID uint64
+		if param := c.Query("user_id"); param != "" {
+			var err error
+			if userID, err = strconv.ParseUint(param, 10, 32); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+				return
+			}
+		}
+
+		page, pageSize := pageQuery(c)
+		logs, total, err := authService.ListAuditLogs(uint(userID), page, pageSize)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list audit logs"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"logs": logs, "total": total})
+	}
+}
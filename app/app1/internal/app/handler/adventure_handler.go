@@ -0,0 +1,106 @@
+package handlers
+
+import (
+	services "app/internal/app/service"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// respondAdventureError 把冒险任务池操作的错误转换为对应的状态码
+func respondAdventureError(c *gin.Context, err error, message string) {
+	switch {
+	case errors.Is(err, services.ErrInvalidAdventure):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrAdventureNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": message})
+	}
+}
+
+// ListAdventureTasksHandler 列出冒险任务池中的全部任务
+func ListAdventureTasksHandler(taskService *services.TaskService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		tasks, err := taskService.ListAdventureTasks()
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list adventure tasks"})
+			return
+		}
+		c.JSON(http.StatusOK, tasks)
+	}
+}
+
+// CreateAdventureTaskHandler 向冒险任务池中添加任务
+func CreateAdventureTaskHandler(taskService *services.TaskService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req struct {
+			Title       string `json:"title"`
+			Description string `json:"description"`
+			Points      int    `json:"points"`
+		}
+		if err := c.BindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request payload"})
+			return
+		}
+
+		task, err := taskService.CreateAdventureTask(req.Title, req.Description, req.Points)
+		if err != nil {
+			respondAdventureError(c, err, "Failed to create adventure task")
+			return
+		}
+		c.JSON(http.StatusCreated, task)
+	}
+}
+
+// UpdateAdventureTaskHandler 修改冒险任务，只修改请求中出现的字段
+func UpdateAdventureTaskHandler(taskService *services.TaskService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid adventure task ID"})
+			return
+		}
+		var req struct {
+			Title       *string `json:"title"`
+			Description *string `json:"description"`
+			Points      *int    `json:"points"`
+			Active      *bool   `json:"active"`
+		}
+		if err := c.BindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request payload"})
+			return
+		}
+
+		task, err := taskService.UpdateAdventureTask(uint(id), services.AdventureUpdate{
+			Title:       req.Title,
+			Description: req.Description,
+			Points:      req.Points,
+			Active:      req.Active,
+		})
+		if err != nil {
+			respondAdventureError(c, err, "Failed to update adventure task")
+			return
+		}
+		c.JSON(http.StatusOK, task)
+	}
+}
+
+// DeleteAdventureTaskHandler 从冒险任务池中删除任务
+func DeleteAdventureTaskHandler(taskService *services.TaskService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid adventure task ID"})
+			return
+		}
+
+		if err := taskService.DeleteAdventureTask(uint(id)); err != nil {
+			respondAdventureError(c, err, "Failed to delete adventure task")
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "Adventure task deleted"})
+	}
+}
@@ -0,0 +1,101 @@
+package handlers
+
+import (
+	models "app/internal/app/model"
+	services "app/internal/app/service"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// APIKeyInfo 返回给客户端的 API key 信息，不包含 key 本身
+type APIKeyInfo struct {
+	ID         uint       `json:"id"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"`
+	Scopes     []string   `json:"scopes"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	ExpiresAt  time.Time  `json:"expires_at"`
+}
+
+func newAPIKeyInfo(key *models.APIKey) APIKeyInfo {
+	return APIKeyInfo{
+		ID:         key.ID,
+		Name:       key.Name,
+		Prefix:     key.Prefix,
+		Scopes:     services.APIKeyScopes(key),
+		CreatedAt:  key.CreatedAt,
+		LastUsedAt: key.LastUsedAt,
+		ExpiresAt:  key.ExpiresAt,
+	}
+}
+
+// CreateAPIKeyHandler 创建个人 API key，key 只在响应中出现一次
+func CreateAPIKeyHandler(authService *services.AuthService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req struct {
+			Name          string   `json:"name"`
+			Scopes        []string `json:"scopes"`
+			ExpiresInDays int      `json:"expires_in_days"`
+		}
+		if err := c.BindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request payload"})
+			return
+		}
+
+		plain, key, err := authService.CreateAPIKey(CurrentUser(c).ID, req.Name, req.Scopes, req.ExpiresInDays)
+		if err != nil {
+			if services.IsAPIKeyError(err) {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			} else {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create API key"})
+			}
+			return
+		}
+
+		c.JSON(http.StatusCreated, gin.H{"key": plain, "api_key": newAPIKeyInfo(key)})
+	}
+}
+
+// ListAPIKeysHandler 列出当前用户的 API key
+func ListAPIKeysHandler(authService *services.AuthService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		keys, err := authService.ListAPIKeys(CurrentUser(c).ID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list API keys"})
+			return
+		}
+
+		result := make([]APIKeyInfo, 0, len(keys))
+		for i := range keys {
+			result = append(result, newAPIKeyInfo(&keys[i]))
+		}
+		c.JSON(http.StatusOK, result)
+	}
+}
+
+// RevokeAPIKeyHandler 撤销当前用户的某个 API key
+func RevokeAPIKeyHandler(authService *services.AuthService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		keyID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid API key ID"})
+			return
+		}
+
+		if err := authService.RevokeAPIKey(CurrentUser(c).ID, uint(keyID)); err != nil {
+			if errors.Is(err, services.ErrAPIKeyNotFound) {
+				c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			} else {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke API key"})
+			}
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "API key revoked"})
+	}
+}
@@ -2,21 +2,18 @@ package handlers
 
 import (
 	services "app/internal/app/service"
+	"errors"
 	"github.com/gin-gonic/gin"
 	"net/http"
-	"strconv"
 )
 
 // 升级用户的处理器
 func UpgradeUserHandler(authService *services.AuthService) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		userID, err := strconv.ParseUint(c.Param("userID"), 10, 32)
-		if err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "无效的用户ID"})
-			return
-		}
+		// 路径中的 userID 已由 RequireSelf 校验，直接使用当前登录用户
+		userID := CurrentUser(c).ID
 
-		err = authService.UpgradeUser(uint(userID))
+		err := authService.UpgradeUser(userID)
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "升级用户失败"})
 			return
@@ -26,10 +23,10 @@ func UpgradeUserHandler(authService *services.AuthService) gin.HandlerFunc {
 	}
 }
 
-// 获取所有每日任务的处理器
+// 获取当前用户所有周期任务的处理器
 func GetDailyTaskHandler(authService *services.AuthService) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		tasks, err := authService.GetDailyTask()
+		tasks, err := authService.GetDailyTask(CurrentUser(c).ID)
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "获取每日任务失败"})
 			return
@@ -39,16 +36,13 @@ func GetDailyTaskHandler(authService *services.AuthService) gin.HandlerFunc {
 	}
 }
 
-// 获取指定用户的每日任务的处理器
+// 获取指定用户今天的周期任务实例的处理器
 func GetDailyTaskByUserIDHandler(authService *services.AuthService) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		userID, err := strconv.ParseUint(c.Param("userID"), 10, 32)
-		if err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "无效的用户ID"})
-			return
-		}
+		// 路径中的 userID 已由 RequireSelf 校验，直接使用当前登录用户
+		userID := CurrentUser(c).ID
 
-		tasks, err := authService.GetDailyTaskByUserID(uint(userID))
+		tasks, err := authService.GetDailyTaskByUserID(userID)
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "获取用户每日任务失败"})
 			return
@@ -61,11 +55,8 @@ func GetDailyTaskByUserIDHandler(authService *services.AuthService) gin.HandlerF
 // 将积分转换为用户经验的处理器
 func ConvertPointsToExperienceHandler(authService *services.AuthService) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		userID, err := strconv.ParseUint(c.Param("userID"), 10, 32)
-		if err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "无效的用户ID"})
-			return
-		}
+		// 路径中的 userID 已由 RequireSelf 校验，直接使用当前登录用户
+		userID := CurrentUser(c).ID
 
 		var req struct {
 			Points int `json:"points"`
@@ -76,9 +67,16 @@ func ConvertPointsToExperienceHandler(authService *services.AuthService) gin.Han
 			return
 		}
 
-		err = authService.ConvertPointsToExperience(uint(userID), req.Points)
+		err := authService.ConvertPointsToExperience(userID, req.Points)
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "积分转换经验失败"})
+			switch {
+			case errors.Is(err, services.ErrInvalidQuantity):
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			case errors.Is(err, services.ErrInsufficientPoints):
+				c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			default:
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "积分转换经验失败"})
+			}
 			return
 		}
 
@@ -0,0 +1,163 @@
+package handlers
+
+import (
+	services "app/internal/app/service"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ListStreaksHandler 列出当前用户每个周期任务的连续打卡情况
+func ListStreaksHandler(taskService *services.TaskService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		streaks, err := taskService.ListStreaks(CurrentUser(c).ID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list streaks"})
+			return
+		}
+		c.JSON(http.StatusOK, streaks)
+	}
+}
+
+// HabitCalendarHandler 返回周期任务的打卡日历，from 和 to 为用户时区的日期，默认为本月
+func HabitCalendarHandler(taskService *services.TaskService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid recurring task ID"})
+			return
+		}
+
+		now := time.Now().In(services.UserLocation(CurrentUser(c)))
+		from := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+		to := from.AddDate(0, 1, -1)
+		if value := c.Query("from"); value != "" {
+			if from, err = time.Parse(services.DateLayout, value); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": services.ErrInvalidDateRange.Error()})
+				return
+			}
+		}
+		if value := c.Query("to"); value != "" {
+			if to, err = time.Parse(services.DateLayout, value); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": services.ErrInvalidDateRange.Error()})
+				return
+			}
+		}
+
+		calendar, err := taskService.GetHabitCalendar(CurrentUser(c).ID, uint(id), from, to)
+		if err != nil {
+			if errors.Is(err, services.ErrInvalidDateRange) {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+			respondRecurringTaskError(c, err, "Failed to get habit calendar")
+			return
+		}
+		c.JSON(http.StatusOK, calendar)
+	}
+}
+
+// respondStreakError 把保护卡和补打卡的错误转换为对应的状态码
+func respondStreakError(c *gin.Context, err error, message string) {
+	switch {
+	case errors.Is(err, services.ErrInvalidQuantity), errors.Is(err, services.ErrInvalidCheckInDate),
+		errors.Is(err, services.ErrNotOccurrence), errors.Is(err, services.ErrOutsideWindow):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrInsufficientPoints), errors.Is(err, services.ErrTooManyFreezes),
+		errors.Is(err, services.ErrNoStreakFreeze), errors.Is(err, services.ErrAlreadyCheckedIn):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		respondRecurringTaskError(c, err, message)
+	}
+}
+
+// GetStreakItemsHandler 返回当前用户持有的保护卡、可用积分和价格
+func GetStreakItemsHandler(taskService *services.TaskService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		items, err := taskService.GetStreakItems(CurrentUser(c).ID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get streak freezes"})
+			return
+		}
+		c.JSON(http.StatusOK, items)
+	}
+}
+
+// BuyStreakFreezesHandler 用积分购买保护卡
+func BuyStreakFreezesHandler(taskService *services.TaskService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req struct {
+			Quantity int `json:"quantity"`
+		}
+		if err := c.BindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request payload"})
+			return
+		}
+
+		items, err := taskService.BuyStreakFreezes(CurrentUser(c).ID, req.Quantity)
+		if err != nil {
+			respondStreakError(c, err, "Failed to buy streak freezes")
+			return
+		}
+		c.JSON(http.StatusOK, items)
+	}
+}
+
+// UseStreakFreezeHandler 用一张保护卡补上周期任务错过的发生日
+func UseStreakFreezeHandler(taskService *services.TaskService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid recurring task ID"})
+			return
+		}
+		var req struct {
+			Date string `json:"date"`
+		}
+		if err := c.BindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request payload"})
+			return
+		}
+
+		checkIn, err := taskService.UseStreakFreeze(CurrentUser(c).ID, uint(id), req.Date)
+		if err != nil {
+			respondStreakError(c, err, "Failed to use streak freeze")
+			return
+		}
+		c.JSON(http.StatusCreated, checkIn)
+	}
+}
+
+// MakeUpCheckInHandler 花积分补打卡，date 默认为用户时区的昨天
+func MakeUpCheckInHandler(taskService *services.TaskService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid recurring task ID"})
+			return
+		}
+		var req struct {
+			Date string `json:"date"`
+		}
+		// 请求体可以为空
+		if c.Request.ContentLength > 0 {
+			if err := c.BindJSON(&req); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request payload"})
+				return
+			}
+		}
+		if req.Date == "" {
+			req.Date = time.Now().In(services.UserLocation(CurrentUser(c))).AddDate(0, 0, -1).Format(services.DateLayout)
+		}
+
+		checkIn, err := taskService.MakeUpCheckIn(CurrentUser(c).ID, uint(id), req.Date)
+		if err != nil {
+			respondStreakError(c, err, "Failed to make up check-in")
+			return
+		}
+		c.JSON(http.StatusCreated, checkIn)
+	}
+}
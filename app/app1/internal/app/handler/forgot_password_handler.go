@@ -2,47 +2,62 @@ package handlers
 
 import (
 	services "app/internal/app/service"
+	"errors"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
 )
 
-func ForgotPasswordHandler(c *gin.Context) {
-	email := c.PostForm("email")
-
-	// 发送验证码邮件给用户
-	verificationCode, err := services.SendVerificationCode(email)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to send verification code"})
-		return
+// ForgotPasswordHandler 申请重置密码，向邮箱发送有时效的重置验证码
+func ForgotPasswordHandler(authService *services.AuthService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		email := c.PostForm("email")
+		if email == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Email is required"})
+			return
+		}
+
+		// 邮箱未注册时同样返回成功，避免探测账号
+		err := authService.RequestPasswordReset(email)
+		if abortIfRateLimited(c, err) {
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to send verification code"})
+			return
+		}
+
+		// 返回成功响应，提示用户检查邮箱获取验证码
+		c.JSON(http.StatusOK, gin.H{"message": "Verification code sent to your email. Please check your inbox."})
 	}
-
-	// 返回成功响应，提示用户检查邮箱获取验证码
-	c.JSON(http.StatusOK, gin.H{"message": "Verification code sent to your email. Please check your inbox."})
-
-	// 保存验证码到上下文中，以便后续验证密码重置请求
-	c.Set("verificationCode", verificationCode)
 }
 
-func ResetPasswordHandler(c *gin.Context) {
-	email := c.PostForm("email")
-	verificationCode := c.PostForm("verification_code")
-	newPassword := c.PostForm("new_password")
-
-	// 从上下文中获取之前发送的验证码
-	savedVerificationCode, exists := c.Get("verificationCode")
-	if !exists || savedVerificationCode.(string) != verificationCode {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid verification code"})
-		return
+// ResetPasswordHandler 使用重置验证码设置新密码，成功后该用户的所有会话都会被注销
+func ResetPasswordHandler(authService *services.AuthService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		email := c.PostForm("email")
+		verificationCode := c.PostForm("verification_code")
+		if verificationCode == "" {
+			// 邮件中的重置链接以 token 参数携带验证码
+			verificationCode = c.PostForm("token")
+		}
+		newPassword := c.PostForm("new_password")
+
+		// 校验验证码并重置密码
+		err := authService.ResetPassword(email, verificationCode, newPassword)
+		if abortIfRateLimited(c, err) {
+			return
+		}
+		if err != nil {
+			if errors.Is(err, services.ErrWeakPassword) || services.IsVerificationError(err) {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			} else {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to reset password"})
+			}
+			return
+		}
+
+		// 返回成功响应
+		c.JSON(http.StatusOK, gin.H{"message": "Password reset successfully"})
 	}
-
-	// 重置密码
-	err := services.ResetPassword(email, verificationCode, newPassword)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to reset password"})
-		return
-	}
-
-	// 返回成功响应
-	c.JSON(http.StatusOK, gin.H{"message": "Password reset successfully"})
 }
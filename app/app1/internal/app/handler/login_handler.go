@@ -3,27 +3,161 @@ package handlers
 import (
 	models "app/internal/app/model"
 	services "app/internal/app/service"
+	"errors"
 	"net/http"
+	"strings"
 
 	"github.com/gin-gonic/gin"
 )
 
-func LoginHandler(c *gin.Context) {
-	var user models.User
-	if err := c.BindJSON(&user); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request payload"})
-		return
+// LoginHandler 登录成功后签发访问令牌和刷新令牌，
+// 支持用户名或邮箱加密码，也支持邮箱加 /login/code 发送的验证码
+func LoginHandler(authService *services.AuthService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req struct {
+			Username string `json:"username"`
+			Email    string `json:"email"`
+			Password string `json:"password"`
+			Code     string `json:"code"`
+		}
+		if err := c.BindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request payload"})
+			return
+		}
+
+		var user *models.User
+		var err error
+		if req.Code != "" {
+			if req.Email == "" {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Email is required when logging in with a code"})
+				return
+			}
+			user, err = authService.LoginWithCode(req.Email, req.Code)
+		} else {
+			// 用户名和邮箱任选其一
+			account := req.Username
+			if account == "" {
+				account = req.Email
+			}
+			if account == "" || req.Password == "" {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Username or email and password are required"})
+				return
+			}
+			user, err = authService.LoginUser(account, req.Password, c.ClientIP())
+		}
+		if abortIfRateLimited(c, err) {
+			return
+		}
+		if err != nil {
+			if errors.Is(err, services.ErrInvalidCredentials) || services.IsVerificationError(err) {
+				c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+			} else if errors.Is(err, services.ErrUserBanned) || errors.Is(err, services.ErrUserDeleted) {
+				c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+			} else {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to login"})
+			}
+			return
+		}
+
+		// 开启两步验证的用户先返回登录凭证，再由 /login/2fa 完成登录
+		if user.TOTPEnabled {
+			mfaToken, err := authService.CreateMFAChallenge(user.ID)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to login"})
+				return
+			}
+			c.JSON(http.StatusOK, gin.H{"mfa_required": true, "mfa_token": mfaToken})
+			return
+		}
+
+		tokens, err := authService.CreateSession(user.ID, clientInfo(c))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create session"})
+			return
+		}
+
+		c.JSON(http.StatusOK, tokens)
+	}
+}
+
+// SendLoginCodeHandler 发送登录验证码
+func SendLoginCodeHandler(authService *services.AuthService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req struct {
+			Email string `json:"email"`
+		}
+		if err := c.BindJSON(&req); err != nil || req.Email == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request payload"})
+			return
+		}
+
+		err := authService.SendVerificationCode(services.PurposeLogin, req.Email)
+		if abortIfRateLimited(c, err) {
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to send verification code"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Verification code sent successfully"})
 	}
+}
+
+// RefreshTokenHandler 使用刷新令牌换取新的令牌对
+func RefreshTokenHandler(authService *services.AuthService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req struct {
+			RefreshToken string `json:"refresh_token"`
+		}
+		if err := c.BindJSON(&req); err != nil || req.RefreshToken == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request payload"})
+			return
+		}
+
+		tokens, err := authService.RefreshSession(req.RefreshToken, clientInfo(c))
+		if err != nil {
+			if errors.Is(err, services.ErrInvalidToken) {
+				c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+			} else if errors.Is(err, services.ErrUserBanned) || errors.Is(err, services.ErrUserDeleted) {
+				c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+			} else {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to refresh token"})
+			}
+			return
+		}
 
-	// 发送验证码
-	verificationCode, err := services.SendVerificationCode(user.Email)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to send verification code"})
-		return
+		c.JSON(http.StatusOK, tokens)
 	}
+}
+
+// LogoutHandler 注销当前访问令牌所属的会话
+func LogoutHandler(authService *services.AuthService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		token := bearerToken(c)
+		if token == "" {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Missing access token"})
+			return
+		}
 
-	// 设置验证码到上下文中
-	c.Set("verificationCode", verificationCode)
+		if err := authService.RevokeSession(token); err != nil {
+			if errors.Is(err, services.ErrInvalidToken) {
+				c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+			} else {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to logout"})
+			}
+			return
+		}
 
-	c.Status(http.StatusOK)
+		c.JSON(http.StatusOK, gin.H{"message": "Logged out successfully"})
+	}
+}
+
+// bearerToken 从 Authorization 请求头中取出 Bearer 令牌
+func bearerToken(c *gin.Context) string {
+	header := c.GetHeader("Authorization")
+	if len(header) > 7 && strings.EqualFold(header[:7], "Bearer ") {
+		return strings.TrimSpace(header[7:])
+	}
+	return ""
 }
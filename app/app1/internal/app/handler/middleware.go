@@ -0,0 +1,163 @@
+package handlers
+
+import (
+	models "app/internal/app/model"
+	services "app/internal/app/service"
+	"errors"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// currentUserKey 当前登录用户在 gin.Context 中的键
+	currentUserKey = "currentUser"
+	// currentSessionKey 当前会话ID在 gin.Context 中的键
+	currentSessionKey = "currentSession"
+	// currentAPIKeyKey 使用 API key 访问时，key 在 gin.Context 中的键
+	currentAPIKeyKey = "currentAPIKey"
+)
+
+// AuthMiddleware 校验访问令牌，并把登录用户放入上下文
+func AuthMiddleware(authService *services.AuthService) gin.HandlerFunc {
+	return authenticate(authService, false)
+}
+
+// APIKeyAuthMiddleware 同 AuthMiddleware，另外接受个人 API key，
+// 用 API key 访问时还需要 RequireScope 检查权限范围
+func APIKeyAuthMiddleware(authService *services.AuthService) gin.HandlerFunc {
+	return authenticate(authService, true)
+}
+
+func authenticate(authService *services.AuthService, allowAPIKey bool) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		token := bearerToken(c)
+		if token == "" {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Missing access token"})
+			return
+		}
+
+		if strings.HasPrefix(token, services.APIKeyPrefix) {
+			if !allowAPIKey {
+				c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "API keys are not accepted on this endpoint"})
+				return
+			}
+			user, key, err := authService.AuthenticateAPIKey(token)
+			if err != nil {
+				abortAuthError(c, err)
+				return
+			}
+			c.Set(currentUserKey, user)
+			c.Set(currentAPIKeyKey, key)
+			c.Next()
+			return
+		}
+
+		user, session, err := authService.AuthenticateUser(token, clientInfo(c))
+		if err != nil {
+			abortAuthError(c, err)
+			return
+		}
+
+		c.Set(currentUserKey, user)
+		c.Set(currentSessionKey, session.ID)
+		c.Next()
+	}
+}
+
+func abortAuthError(c *gin.Context, err error) {
+	if errors.Is(err, services.ErrInvalidToken) {
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+	} else if errors.Is(err, services.ErrUserBanned) || errors.Is(err, services.ErrUserDeleted) {
+		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	} else {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "Failed to authenticate"})
+	}
+}
+
+// RequireScope 用 API key 访问时检查权限范围，使用会话登录的请求直接放行
+func RequireScope(scope string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		value, ok := c.Get(currentAPIKeyKey)
+		if !ok {
+			c.Next()
+			return
+		}
+		if !services.APIKeyHasScope(value.(*models.APIKey), scope) {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "API key does not have the " + scope + " scope"})
+			return
+		}
+		c.Next()
+	}
+}
+
+// RequirePermission 当前用户的角色没有指定权限时返回 403
+func RequirePermission(perm services.Permission) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !services.HasPermission(CurrentUser(c), perm) {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "Permission denied"})
+			return
+		}
+		c.Next()
+	}
+}
+
+// RequireSelf 拒绝操作其他用户资源的请求，路径中的 userID 必须是当前登录用户；
+// /me 路由没有 userID 参数，直接放行
+func RequireSelf() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		param := c.Param("userID")
+		if param == "" {
+			c.Next()
+			return
+		}
+
+		userID, err := strconv.ParseUint(param, 10, 32)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "无效的用户ID"})
+			return
+		}
+		if uint(userID) != CurrentUser(c).ID {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "无权操作其他用户"})
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// CurrentUser 返回 AuthMiddleware 放入上下文的登录用户
+func CurrentUser(c *gin.Context) *models.User {
+	user, _ := c.MustGet(currentUserKey).(*models.User)
+	return user
+}
+
+// clientInfo 取出请求的 User-Agent 和客户端 IP，记录在会话中
+func clientInfo(c *gin.Context) services.ClientInfo {
+	return services.ClientInfo{Device: c.Request.UserAgent(), IP: c.ClientIP()}
+}
+
+// RateLimitByIP 按客户端 IP 限流，超过限制返回 429
+func RateLimitByIP(limiter *services.RateLimiter) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if ok, retry := limiter.Allow(c.ClientIP()); !ok {
+			abortIfRateLimited(c, &services.RateLimitError{RetryAfter: retry})
+			return
+		}
+		c.Next()
+	}
+}
+
+// abortIfRateLimited 如果是限流错误，返回 429 并通过 Retry-After 告知等待秒数
+func abortIfRateLimited(c *gin.Context, err error) bool {
+	var limited *services.RateLimitError
+	if !errors.As(err, &limited) {
+		return false
+	}
+	c.Header("Retry-After", strconv.Itoa(int(math.Ceil(limited.RetryAfter.Seconds()))))
+	c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{"error": limited.Error()})
+	return true
+}
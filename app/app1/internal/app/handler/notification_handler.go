@@ -0,0 +1,56 @@
+package handlers
+
+import (
+	services "app/internal/app/service"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ListNotificationsHandler 分页列出当前用户的通知，unread=true 时只列出未读通知
+func ListNotificationsHandler(authService *services.AuthService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		unreadOnly, _ := strconv.ParseBool(c.Query("unread"))
+		page, pageSize := pageQuery(c)
+		notifications, total, unread, err := authService.ListNotifications(CurrentUser(c).ID, unreadOnly, page, pageSize)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list notifications"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"notifications": notifications, "total": total, "unread": unread})
+	}
+}
+
+// MarkNotificationReadHandler 把一条通知标记为已读
+func MarkNotificationReadHandler(authService *services.AuthService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid notification ID"})
+			return
+		}
+
+		if err := authService.MarkNotificationRead(CurrentUser(c).ID, uint(id)); err != nil {
+			if errors.Is(err, services.ErrNotificationNotFound) {
+				c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to mark notification as read"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "Notification marked as read"})
+	}
+}
+
+// MarkAllNotificationsReadHandler 把当前用户的全部通知标记为已读
+func MarkAllNotificationsReadHandler(authService *services.AuthService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if err := authService.MarkAllNotificationsRead(CurrentUser(c).ID); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to mark notifications as read"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "All notifications marked as read"})
+	}
+}
@@ -0,0 +1,149 @@
+package handlers
+
+import (
+	services "app/internal/app/service"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// respondOIDCError 把第三方登录相关的错误转换为对应的状态码
+func respondOIDCError(c *gin.Context, err error, message string) {
+	switch {
+	case errors.Is(err, services.ErrOIDCProviderNotFound), errors.Is(err, services.ErrIdentityNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrIdentityLinked), errors.Is(err, services.ErrIdentityAlreadyLinked):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrUserBanned), errors.Is(err, services.ErrUserDeleted):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	case services.IsOIDCError(err):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusBadGateway, gin.H{"error": message})
+	}
+}
+
+// ListOIDCProvidersHandler 列出可用的第三方登录方式
+func ListOIDCProvidersHandler(authService *services.AuthService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		providers := make([]gin.H, 0, len(authService.OIDCProviders()))
+		for _, p := range authService.OIDCProviders() {
+			providers = append(providers, gin.H{"name": p.Name(), "display_name": p.DisplayName()})
+		}
+		c.JSON(http.StatusOK, providers)
+	}
+}
+
+// OIDCLoginHandler 跳转到 provider 的登录页面
+func OIDCLoginHandler(authService *services.AuthService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		authURL, err := authService.StartOIDCLogin(c.Param("provider"), 0)
+		if err != nil {
+			respondOIDCError(c, err, "Login provider is unavailable")
+			return
+		}
+		c.Redirect(http.StatusFound, authURL)
+	}
+}
+
+// OIDCCallbackHandler provider 登录完成后回调，code 和 state 可以由前端页面原样转发；
+// 登录流程签发令牌，开启两步验证的用户返回 mfa_token；绑定流程返回绑定结果
+func OIDCCallbackHandler(authService *services.AuthService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if errParam := c.Query("error"); errParam != "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Login was cancelled or denied: " + errParam})
+			return
+		}
+		code, state := c.Query("code"), c.Query("state")
+		if code == "" || state == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Missing code or state"})
+			return
+		}
+
+		user, linking, err := authService.FinishOIDCLogin(c.Param("provider"), state, code)
+		if err != nil {
+			respondOIDCError(c, err, "Failed to login with provider")
+			return
+		}
+
+		if linking {
+			c.JSON(http.StatusOK, gin.H{"message": "Identity linked successfully"})
+			return
+		}
+
+		if user.TOTPEnabled {
+			mfaToken, err := authService.CreateMFAChallenge(user.ID)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to login"})
+				return
+			}
+			c.JSON(http.StatusOK, gin.H{"mfa_required": true, "mfa_token": mfaToken})
+			return
+		}
+
+		tokens, err := authService.CreateSession(user.ID, clientInfo(c))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create session"})
+			return
+		}
+		c.JSON(http.StatusOK, tokens)
+	}
+}
+
+// LinkIdentityHandler 已登录用户绑定新的第三方身份，返回需要打开的授权地址
+func LinkIdentityHandler(authService *services.AuthService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		authURL, err := authService.StartOIDCLogin(c.Param("provider"), CurrentUser(c).ID)
+		if err != nil {
+			respondOIDCError(c, err, "Login provider is unavailable")
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"authorization_url": authURL})
+	}
+}
+
+// ListIdentitiesHandler 列出当前用户绑定的第三方身份
+func ListIdentitiesHandler(authService *services.AuthService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		identities, err := authService.ListIdentities(CurrentUser(c).ID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list identities"})
+			return
+		}
+
+		result := make([]gin.H, 0, len(identities))
+		for _, identity := range identities {
+			result = append(result, gin.H{
+				"id":            identity.ID,
+				"provider":      identity.Provider,
+				"email":         identity.Email,
+				"linked_at":     identity.CreatedAt,
+				"last_login_at": identity.LastLoginAt,
+			})
+		}
+		c.JSON(http.StatusOK, gin.H{"has_password": CurrentUser(c).Password != "", "identities": result})
+	}
+}
+
+// UnlinkIdentityHandler 解除第三方身份绑定
+func UnlinkIdentityHandler(authService *services.AuthService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		identityID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid identity ID"})
+			return
+		}
+
+		if err := authService.UnlinkIdentity(CurrentUser(c).ID, uint(identityID)); err != nil {
+			if errors.Is(err, services.ErrIdentityNotFound) || errors.Is(err, services.ErrLastLoginMethod) {
+				respondOIDCError(c, err, "")
+			} else {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to unlink identity"})
+			}
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "Identity unlinked"})
+	}
+}
@@ -0,0 +1,178 @@
+package handlers
+
+import (
+	models "app/internal/app/model"
+	services "app/internal/app/service"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// UserProfile 返回给客户端的用户资料，不包含密码和验证码
+type UserProfile struct {
+	ID                  uint       `json:"id"`
+	Email               string     `json:"email"`
+	Username            string     `json:"username"`
+	AvatarURL           string     `json:"avatar_url"`
+	Bio                 string     `json:"bio"`
+	Timezone            string     `json:"timezone"`
+	Locale              string     `json:"locale"`
+	Level               int        `json:"level"`
+	Experience          int        `json:"experience"`
+	SelfImprovementExp  int        `json:"self_improvement_exp"`
+	WorkExp             int        `json:"work_exp"`
+	HabitExp            int        `json:"habit_exp"`
+	TodoExp             int        `json:"todo_exp"`
+	TwoFactorEnabled    bool       `json:"two_factor_enabled"`
+	Role                string     `json:"role"`
+	DeletionScheduledAt *time.Time `json:"deletion_scheduled_at"` // 申请注销后计划删除数据的时间
+	CreatedAt           time.Time  `json:"created_at"`
+}
+
+// newUserProfile 从用户记录生成资料，未设置的时区和语言返回默认值
+func newUserProfile(user *models.User) UserProfile {
+	profile := UserProfile{
+		ID:                  user.ID,
+		Email:               user.Email,
+		Username:            user.Username,
+		AvatarURL:           user.AvatarURL,
+		Bio:                 user.Bio,
+		Timezone:            user.Timezone,
+		Locale:              user.Locale,
+		Level:               user.Level,
+		Experience:          user.Experience,
+		SelfImprovementExp:  user.SelfImprovementExp,
+		WorkExp:             user.WorkExp,
+		HabitExp:            user.HabitExp,
+		TodoExp:             user.TodoExp,
+		TwoFactorEnabled:    user.TOTPEnabled,
+		Role:                services.UserRole(user),
+		DeletionScheduledAt: user.DeletionScheduledAt,
+		CreatedAt:           user.CreatedAt,
+	}
+	if profile.Timezone == "" {
+		profile.Timezone = services.DefaultTimezone
+	}
+	if profile.Locale == "" {
+		profile.Locale = services.DefaultLocale
+	}
+	return profile
+}
+
+// respondProfileError 把资料相关的错误转换为对应的状态码
+func respondProfileError(c *gin.Context, err error, message string) {
+	if abortIfRateLimited(c, err) {
+		return
+	}
+	switch {
+	case services.IsProfileError(err), services.IsVerificationError(err):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrWrongPassword):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrUserExists):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrUserNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": message})
+	}
+}
+
+// GetProfileHandler 获取当前用户的资料、等级和经验
+func GetProfileHandler(authService *services.AuthService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user, err := authService.GetUserExperienceAndLevel(uint64(CurrentUser(c).ID))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get profile"})
+			return
+		}
+
+		c.JSON(http.StatusOK, newUserProfile(user))
+	}
+}
+
+// UpdateProfileHandler 修改当前用户的资料，只修改请求中出现的字段
+func UpdateProfileHandler(authService *services.AuthService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req struct {
+			Username  *string `json:"username"`
+			AvatarURL *string `json:"avatar_url"`
+			Bio       *string `json:"bio"`
+			Timezone  *string `json:"timezone"`
+			Locale    *string `json:"locale"`
+		}
+		if err := c.BindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request payload"})
+			return
+		}
+
+		user, err := authService.UpdateProfile(CurrentUser(c).ID, services.ProfileUpdate{
+			Username:  req.Username,
+			AvatarURL: req.AvatarURL,
+			Bio:       req.Bio,
+			Timezone:  req.Timezone,
+			Locale:    req.Locale,
+		})
+		if err != nil {
+			respondProfileError(c, err, "Failed to update profile")
+			return
+		}
+
+		c.JSON(http.StatusOK, newUserProfile(user))
+	}
+}
+
+// ChangePasswordHandler 校验当前密码后修改密码，所有设备需要重新登录
+func ChangePasswordHandler(authService *services.AuthService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req struct {
+			CurrentPassword string `json:"current_password"`
+			NewPassword     string `json:"new_password"`
+		}
+		if err := c.BindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request payload"})
+			return
+		}
+
+		if err := authService.ChangePassword(CurrentUser(c).ID, req.CurrentPassword, req.NewPassword); err != nil {
+			respondProfileError(c, err, "Failed to change password")
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Password changed successfully, please login again"})
+	}
+}
+
+// ChangeEmailHandler 修改邮箱：不带 code 时向新邮箱发送验证码，带 code 时校验并修改
+func ChangeEmailHandler(authService *services.AuthService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req struct {
+			Email string `json:"email"`
+			Code  string `json:"code"`
+		}
+		if err := c.BindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request payload"})
+			return
+		}
+
+		userID := CurrentUser(c).ID
+		if req.Code == "" {
+			if err := authService.RequestEmailChange(userID, req.Email); err != nil {
+				respondProfileError(c, err, "Failed to send verification code")
+				return
+			}
+			c.JSON(http.StatusAccepted, gin.H{"message": "Verification code sent to the new email"})
+			return
+		}
+
+		user, err := authService.ConfirmEmailChange(userID, req.Email, req.Code)
+		if err != nil {
+			respondProfileError(c, err, "Failed to change email")
+			return
+		}
+
+		c.JSON(http.StatusOK, newUserProfile(user))
+	}
+}
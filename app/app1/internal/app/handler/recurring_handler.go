@@ -0,0 +1,143 @@
+package handlers
+
+import (
+	models "app/internal/app/model"
+	services "app/internal/app/service"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RecurringTaskInfo 周期任务及其下一个发生日
+type RecurringTaskInfo struct {
+	models.RecurringTask
+	NextOccurrence string `json:"next_occurrence"` // 今天或之后的下一个发生日，已结束时为空
+}
+
+func newRecurringTaskInfo(c *gin.Context, task *models.RecurringTask) RecurringTaskInfo {
+	return RecurringTaskInfo{
+		RecurringTask:  *task,
+		NextOccurrence: services.NextOccurrence(task, time.Now(), services.UserLocation(CurrentUser(c))),
+	}
+}
+
+// respondRecurringTaskError 把周期任务操作的错误转换为对应的状态码
+func respondRecurringTaskError(c *gin.Context, err error, message string) {
+	switch {
+	case errors.Is(err, services.ErrInvalidRRule), errors.Is(err, services.ErrInvalidStartDate),
+		errors.Is(err, services.ErrInvalidTask), errors.Is(err, services.ErrInvalidCategory):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrRecurringTaskNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": message})
+	}
+}
+
+// ListRecurringTasksHandler 列出当前用户的周期任务
+func ListRecurringTasksHandler(taskService *services.TaskService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		tasks, err := taskService.ListRecurringTasks(CurrentUser(c).ID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list recurring tasks"})
+			return
+		}
+
+		result := make([]RecurringTaskInfo, 0, len(tasks))
+		for i := range tasks {
+			result = append(result, newRecurringTaskInfo(c, &tasks[i]))
+		}
+		c.JSON(http.StatusOK, result)
+	}
+}
+
+// CreateRecurringTaskHandler 创建周期任务
+func CreateRecurringTaskHandler(taskService *services.TaskService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req struct {
+			Title       string `json:"title"`
+			Description string `json:"description"`
+			Points      int    `json:"points"`
+			Category    string `json:"category"`
+			RRule       string `json:"rrule"`
+			StartDate   string `json:"start_date"`
+		}
+		if err := c.BindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request payload"})
+			return
+		}
+
+		task, err := taskService.CreateRecurringTask(CurrentUser(c).ID, services.NewRecurringTask{
+			Title:       req.Title,
+			Description: req.Description,
+			Points:      req.Points,
+			Category:    req.Category,
+			RRule:       req.RRule,
+			StartDate:   req.StartDate,
+		})
+		if err != nil {
+			respondRecurringTaskError(c, err, "Failed to create recurring task")
+			return
+		}
+		c.JSON(http.StatusCreated, newRecurringTaskInfo(c, task))
+	}
+}
+
+// UpdateRecurringTaskHandler 修改周期任务，只修改请求中出现的字段
+func UpdateRecurringTaskHandler(taskService *services.TaskService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid recurring task ID"})
+			return
+		}
+		var req struct {
+			Title       *string `json:"title"`
+			Description *string `json:"description"`
+			Points      *int    `json:"points"`
+			Category    *string `json:"category"`
+			RRule       *string `json:"rrule"`
+			StartDate   *string `json:"start_date"`
+			Active      *bool   `json:"active"`
+		}
+		if err := c.BindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request payload"})
+			return
+		}
+
+		task, err := taskService.UpdateRecurringTask(uint(id), CurrentUser(c).ID, services.RecurringTaskUpdate{
+			Title:       req.Title,
+			Description: req.Description,
+			Points:      req.Points,
+			Category:    req.Category,
+			RRule:       req.RRule,
+			StartDate:   req.StartDate,
+			Active:      req.Active,
+		})
+		if err != nil {
+			respondRecurringTaskError(c, err, "Failed to update recurring task")
+			return
+		}
+		c.JSON(http.StatusOK, newRecurringTaskInfo(c, task))
+	}
+}
+
+// DeleteRecurringTaskHandler 删除周期任务，已经生成的任务保留
+func DeleteRecurringTaskHandler(taskService *services.TaskService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid recurring task ID"})
+			return
+		}
+
+		if err := taskService.DeleteRecurringTask(uint(id), CurrentUser(c).ID); err != nil {
+			respondRecurringTaskError(c, err, "Failed to delete recurring task")
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "Recurring task deleted"})
+	}
+}
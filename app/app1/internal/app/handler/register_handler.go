@@ -1,89 +1,93 @@
 package handlers
 
 import (
-	models "app/internal/app/model"
 	services "app/internal/app/service"
+	"errors"
 	"github.com/gin-gonic/gin"
-	"github.com/go-gomail/gomail"
 	"net/http"
 )
 
-type Config struct {
-	SmtpServer   string `json:"smtp_server"`
-	SmtpPort     int    `json:"smtp_port"`
-	SmtpUsername string `json:"smtp_username"`
-	SmtpPassword string `json:"smtp_password"`
-}
+// RegisterHandler 处理用户注册请求，保存注册信息并向邮箱发送验证码
+func RegisterHandler(authService *services.AuthService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req struct {
+			Email    string `json:"email"`
+			Username string `json:"username"`
+			Password string `json:"password"`
+		}
+		if err := c.BindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request payload"})
+			return
+		}
 
-// RegisterHandler 处理用户注册请求
-func RegisterHandler(c *gin.Context) {
-	var user models.User
-	if err := c.BindJSON(&user); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request payload"})
-		return
-	}
-
-	// 发送验证码到用户填写的邮箱
-	verificationCode, err := services.SendVerificationCode(user.Email)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to send verification code"})
-		return
-	}
+		err := authService.StartRegistration(req.Email, req.Username, req.Password)
+		if abortIfRateLimited(c, err) {
+			return
+		}
+		if err != nil {
+			switch {
+			case errors.Is(err, services.ErrInvalidSignup), errors.Is(err, services.ErrWeakPassword):
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			case errors.Is(err, services.ErrUserExists):
+				c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			default:
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to send verification code"})
+			}
+			return
+		}
 
-	// 存储验证码到缓存中
-	err = services.StoreVerificationCode(user.Email, verificationCode)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to store verification code"})
-		return
+		c.JSON(http.StatusOK, gin.H{"message": "Verification code sent successfully"})
 	}
-
-	c.JSON(http.StatusOK, gin.H{"message": "Verification code sent successfully"})
 }
 
-// VerifyCodeHandler 处理验证码验证请求
-func VerifyCodeHandler(c *gin.Context) {
-	// 从请求中获取用户填写的验证码
-	verificationCode := c.PostForm("verification_code")
-	userEmail := c.PostForm("email")
+// VerifyCodeHandler 校验注册验证码，创建用户并直接返回登录令牌
+func VerifyCodeHandler(authService *services.AuthService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		// 同时支持 JSON 和表单
+		var req struct {
+			Email            string `json:"email" form:"email"`
+			VerificationCode string `json:"verification_code" form:"verification_code"`
+		}
+		if err := c.ShouldBind(&req); err != nil || req.Email == "" || req.VerificationCode == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request payload"})
+			return
+		}
 
-	// 从缓存中获取之前发送的验证码
-	storedCode, err := services.GetVerificationCode(userEmail)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve verification code"})
-		return
-	}
+		user, err := authService.CompleteRegistration(req.Email, req.VerificationCode)
+		if err != nil {
+			switch {
+			case errors.Is(err, services.ErrSignupNotFound):
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			case errors.Is(err, services.ErrUserExists):
+				c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			default:
+				respondVerificationError(c, err)
+			}
+			return
+		}
 
-	// 比较用户填写的验证码和之前发送的验证码
-	if verificationCode != storedCode {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid verification code"})
-		return
-	}
+		tokens, err := authService.CreateSession(user.ID, clientInfo(c))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "User registered, but failed to create session"})
+			return
+		}
 
-	// 验证码正确，注册用户
-	var user models.User
-	if err := c.BindJSON(&user); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request payload"})
-		return
+		c.JSON(http.StatusCreated, gin.H{
+			"message": "User registered successfully",
+			"user_id": user.ID,
+			"tokens":  tokens,
+		})
 	}
+}
 
-	if err := services.RegisterUser(user); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+// respondVerificationError 限流返回 429，验证码错误返回 400，其余返回 500
+func respondVerificationError(c *gin.Context, err error) {
+	if abortIfRateLimited(c, err) {
 		return
 	}
-
-	c.JSON(http.StatusCreated, gin.H{"message": "User registered successfully"})
-}
-
-func SendVerificationEmail(email, code string, config *Config) error {
-	m := gomail.NewMessage()
-	m.SetHeader("From", config.SmtpUsername)
-	m.SetHeader("To", email)
-	m.SetHeader("Subject", "Verification Code")
-	m.SetBody("text/plain", "Your verification code is: "+code)
-
-	d := gomail.NewDialer(config.SmtpServer, config.SmtpPort, config.SmtpUsername, config.SmtpPassword)
-	if err := d.DialAndSend(m); err != nil {
-		return err
+	if services.IsVerificationError(err) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
 	}
-	return nil
+	c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify code"})
 }
@@ -0,0 +1,81 @@
+package handlers
+
+import (
+	services "app/internal/app/service"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SessionInfo 返回给客户端的会话信息，不包含令牌
+type SessionInfo struct {
+	ID         uint      `json:"id"`
+	Device     string    `json:"device"`
+	IP         string    `json:"ip"`
+	CreatedAt  time.Time `json:"created_at"`
+	LastSeenAt time.Time `json:"last_seen_at"`
+	Current    bool      `json:"current"` // 是否为发起本次请求的会话
+}
+
+// ListSessionsHandler 列出当前用户已登录的设备
+func ListSessionsHandler(authService *services.AuthService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		sessions, err := authService.ListSessions(CurrentUser(c).ID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list sessions"})
+			return
+		}
+
+		currentID := c.GetUint(currentSessionKey)
+		result := make([]SessionInfo, 0, len(sessions))
+		for _, session := range sessions {
+			result = append(result, SessionInfo{
+				ID:         session.ID,
+				Device:     session.Device,
+				IP:         session.IP,
+				CreatedAt:  session.CreatedAt,
+				LastSeenAt: session.LastSeenAt,
+				Current:    session.ID == currentID,
+			})
+		}
+
+		c.JSON(http.StatusOK, result)
+	}
+}
+
+// RevokeSessionHandler 注销当前用户的某个会话，该设备需要重新登录
+func RevokeSessionHandler(authService *services.AuthService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		sessionID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid session ID"})
+			return
+		}
+
+		if err := authService.RevokeUserSession(CurrentUser(c).ID, uint(sessionID)); err != nil {
+			if errors.Is(err, services.ErrSessionNotFound) {
+				c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			} else {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke session"})
+			}
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Session revoked"})
+	}
+}
+
+// RevokeAllSessionsHandler 注销当前用户的全部会话，包括本次请求使用的会话
+func RevokeAllSessionsHandler(authService *services.AuthService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if err := authService.RevokeUserSessions(CurrentUser(c).ID); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke sessions"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "All sessions revoked"})
+	}
+}
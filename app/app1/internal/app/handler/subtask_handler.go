@@ -0,0 +1,116 @@
+package handlers
+
+import (
+	services "app/internal/app/service"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// respondSubTaskError 把子任务操作的错误转换为对应的状态码
+func respondSubTaskError(c *gin.Context, err error, message string) {
+	switch {
+	case errors.Is(err, services.ErrInvalidSubTaskOrder):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrSubTaskNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	default:
+		respondTaskError(c, err, message)
+	}
+}
+
+// ListSubTasksHandler 按顺序列出任务的子任务和完成进度
+func ListSubTasksHandler(taskService *services.TaskService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid task ID"})
+			return
+		}
+
+		list, err := taskService.ListSubTasks(uint(id), CurrentUser(c).ID)
+		if err != nil {
+			respondSubTaskError(c, err, "Failed to list subtasks")
+			return
+		}
+		c.JSON(http.StatusOK, list)
+	}
+}
+
+// CompleteSubTaskHandler 完成子任务，全部子任务完成时父任务自动完成
+func CompleteSubTaskHandler(taskService *services.TaskService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid subtask ID"})
+			return
+		}
+
+		list, err := taskService.CompleteSubTask(uint(id), CurrentUser(c).ID)
+		if err != nil {
+			respondSubTaskError(c, err, "Failed to complete subtask")
+			return
+		}
+		c.JSON(http.StatusOK, list)
+	}
+}
+
+// UpdateSubTaskHandler 修改子任务的标题、描述和积分，只修改请求中出现的字段
+func UpdateSubTaskHandler(taskService *services.TaskService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid subtask ID"})
+			return
+		}
+
+		var req struct {
+			Title       *string `json:"title"`
+			Description *string `json:"description"`
+			Points      *int    `json:"points"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request payload"})
+			return
+		}
+
+		list, err := taskService.UpdateSubTask(uint(id), CurrentUser(c).ID, services.SubTaskUpdate{
+			Title:       req.Title,
+			Description: req.Description,
+			Points:      req.Points,
+		})
+		if err != nil {
+			respondSubTaskError(c, err, "Failed to update subtask")
+			return
+		}
+		c.JSON(http.StatusOK, list)
+	}
+}
+
+// ReorderSubTasksHandler 按请求中的顺序重新排列任务的子任务
+func ReorderSubTasksHandler(taskService *services.TaskService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid task ID"})
+			return
+		}
+
+		var req struct {
+			SubTaskIDs []uint `json:"subtask_ids" binding:"required"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request payload"})
+			return
+		}
+
+		list, err := taskService.ReorderSubTasks(uint(id), CurrentUser(c).ID, req.SubTaskIDs)
+		if err != nil {
+			respondSubTaskError(c, err, "Failed to reorder subtasks")
+			return
+		}
+		c.JSON(http.StatusOK, list)
+	}
+}
@@ -3,172 +3,462 @@ package handlers
 import (
 	models "app/internal/app/model"
 	services "app/internal/app/service"
+	"errors"
 	"net/http"
 	"strconv"
+	"time"
 
 	"github.com/gin-gonic/gin"
 )
 
-var taskService = services.NewTaskService(db)
+// respondTaskError 把任务操作的错误转换为对应的状态码
+func respondTaskError(c *gin.Context, err error, message string) {
+	switch {
+	case errors.Is(err, services.ErrInvalidTask), errors.Is(err, services.ErrInvalidTags), errors.Is(err, services.ErrInvalidCategory),
+		errors.Is(err, services.ErrInvalidTaskSort), errors.Is(err, services.ErrInvalidCursor), errors.Is(err, services.ErrInvalidReminders),
+		errors.Is(err, services.ErrSubTaskPoints), errors.Is(err, services.ErrCombinationPoints):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrTaskNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrTaskForbidden), errors.Is(err, services.ErrNotTeamMember):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrTaskRewarded), errors.Is(err, services.ErrSubTasksIncomplete):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": message})
+	}
+}
 
 // CreateTaskHandler 创建任务处理函数
 func CreateTaskHandler(taskService *services.TaskService) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		var task models.Task
-		if err := c.BindJSON(&task); err != nil {
+		var req struct {
+			Title       string   `json:"title"`
+			Description string   `json:"description"`
+			Points      int      `json:"points"`
+			Category    string   `json:"category"`
+			Tags        []string `json:"tags"`
+			DueAt       string   `json:"due_at"`
+			Reminders   []int    `json:"reminders"`
+		}
+		if err := c.BindJSON(&req); err != nil {
 			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request payload"})
 			return
 		}
+		due, err := parseDue(c, req.DueAt)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
 
-		err := taskService.CreateTask(task.UserID, task.Title, task.Description, task.Points)
+		// 任务归属于当前登录用户，忽略请求体中的 user_id
+		created, err := taskService.CreateTask(CurrentUser(c).ID, services.NewTask{
+			Title:       req.Title,
+			Description: req.Description,
+			Points:      req.Points,
+			Category:    req.Category,
+			Tags:        req.Tags,
+			Due:         due,
+			Reminders:   req.Reminders,
+		})
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			respondTaskError(c, err, "Failed to create task")
 			return
 		}
 
-		c.JSON(http.StatusCreated, task)
+		c.JSON(http.StatusCreated, created)
 	}
 }
 
 // GetTaskHandler 根据ID获取任务处理函数
 func GetTaskHandler(taskService *services.TaskService) gin.HandlerFunc {
-    return func(c *gin.Context) {
-        id, err := strconv.ParseUint(c.Param("id"), 10, 32)
-        if err != nil {
-            c.JSON(http.StatusBadRequest, gin.H{"error": "invalid task ID"})
-            return
-        }
+	return func(c *gin.Context) {
+		id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid task ID"})
+			return
+		}
 
-        task, err := taskService.GetTaskByID(uint(id))
-        if err != nil {
-            if err.Error() == "task not found" {
-                c.JSON(http.StatusNotFound, gin.H{"error": "task not found"})
-            } else {
-                c.JSON(http.StatusInternalServerError, gin.H{"error": "database error"})
-            }
-            return
-        }
+		task, err := taskService.GetTaskForUser(uint(id), CurrentUser(c).ID)
+		if err != nil {
+			respondTaskError(c, err, "database error")
+			return
+		}
 
-        c.JSON(http.StatusOK, task)
-    }
+		c.JSON(http.StatusOK, task)
+	}
 }
 
+// UpdateTaskHandler 修改任务，只修改请求中出现的字段
+func UpdateTaskHandler(taskService *services.TaskService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid task ID"})
+			return
+		}
+		var req struct {
+			Title       *string   `json:"title"`
+			Description *string   `json:"description"`
+			Points      *int      `json:"points"`
+			Completed   *bool     `json:"completed"`
+			Category    *string   `json:"category"`
+			Tags        *[]string `json:"tags"`
+			DueAt       *string   `json:"due_at"` // 空字符串清除截止时间
+			Reminders   *[]int    `json:"reminders"`
+		}
+		if err := c.BindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request payload"})
+			return
+		}
+		var due *services.TaskDue
+		if req.DueAt != nil {
+			parsed, err := parseDue(c, *req.DueAt)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+			due = &parsed
+		}
 
-// GetRandomDailyTaskHandler 获取每日打卡任务处理函数
-func GetRandomDailyTaskHandler(c *gin.Context) {
-	// 从URL路径中解析userID
-	userIDStr := c.Param("userID")
-	userID, err := strconv.ParseUint(userIDStr, 10, 32)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
-		return
+		task, err := taskService.UpdateTask(uint(id), CurrentUser(c).ID, services.TaskUpdate{
+			Title:       req.Title,
+			Description: req.Description,
+			Points:      req.Points,
+			Completed:   req.Completed,
+			Category:    req.Category,
+			Tags:        req.Tags,
+			Due:         due,
+			Reminders:   req.Reminders,
+		})
+		if err != nil {
+			respondTaskError(c, err, "Failed to update task")
+			return
+		}
+		c.JSON(http.StatusOK, task)
 	}
+}
 
-	taskService := services.TaskService{DB: db}
+// DeleteTaskHandler 删除任务及其子任务
+func DeleteTaskHandler(taskService *services.TaskService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid task ID"})
+			return
+		}
 
-	// 调用服务获取任务
-	task, err := taskService.GetRandomDailyTask(uint(userID))
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching task"})
-		return
+		if err := taskService.DeleteTask(uint(id), CurrentUser(c).ID); err != nil {
+			respondTaskError(c, err, "Failed to delete task")
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "Task deleted"})
 	}
+}
 
-	// 检查是否找到了任务
-	if task == nil {
-		c.JSON(http.StatusOK, gin.H{"message": "No tasks found for the user"})
-		return
+// parseDue 解析截止时间：RFC 3339 时间，或只有日期（截止到用户时区当天结束），空字符串表示没有截止时间
+func parseDue(c *gin.Context, value string) (services.TaskDue, error) {
+	if value == "" {
+		return services.TaskDue{}, nil
 	}
-
-	// 成功找到任务，返回给客户端
-	c.JSON(http.StatusOK, task)
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return services.TaskDue{At: &t}, nil
+	}
+	day, err := time.Parse(services.DateLayout, value)
+	if err != nil {
+		return services.TaskDue{}, errors.New("due_at must be a date (2006-01-02) or an RFC 3339 time")
+	}
+	end := services.EndOfDay(day, services.UserLocation(CurrentUser(c)))
+	return services.TaskDue{At: &end, AllDay: true}, nil
 }
 
-// MarkTaskCompletedHandler 打卡任务完成处理函数
-func MarkTaskCompletedHandler(c *gin.Context) {
-	// 从请求路径中提取 taskID
-	taskIDStr := c.Param("taskID")
-	taskID, err := strconv.ParseUint(taskIDStr, 10, 32)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid task ID"})
-		return
+// taskQuery 读取任务列表的筛选、排序和分页参数，日期按用户时区解析
+func taskQuery(c *gin.Context) (services.TaskQuery, error) {
+	query := services.TaskQuery{
+		TaskType: c.Query("task_type"),
+		Category: c.Query("category"),
+		Tag:      c.Query("tag"),
+		Sort:     c.Query("sort"),
+		Cursor:   c.Query("cursor"),
+	}
+	if v := c.Query("completed"); v != "" {
+		completed, err := strconv.ParseBool(v)
+		if err != nil {
+			return query, errors.New("completed must be true or false")
+		}
+		query.Completed = &completed
 	}
 
-	// 调用服务层方法来标记任务为完成
-	err = taskService.MarkTaskAsCompleted(uint(taskID))
-	if err != nil {
-		// 如果更新时遇到错误，向客户端返回错误信息
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to mark task as completed"})
-		return
+	loc := services.UserLocation(CurrentUser(c))
+	for _, p := range []struct {
+		name   string
+		target **time.Time
+		endOf  bool
+	}{{"created_from", &query.CreatedAfter, false}, {"created_to", &query.CreatedBefore, true},
+		{"due_from", &query.DueAfter, false}, {"due_to", &query.DueBefore, true}} {
+		v := c.Query(p.name)
+		if v == "" {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			// 只有日期时，created_to 和 due_to 包含当天
+			day, err := time.ParseInLocation("2006-01-02", v, loc)
+			if err != nil {
+				return query, errors.New(p.name + " must be a date (2006-01-02) or an RFC 3339 time")
+			}
+			t = day
+			if p.endOf {
+				t = day.AddDate(0, 0, 1)
+			}
+		}
+		*p.target = &t
 	}
 
-	// 如果成功，向客户端返回成功信息
-	c.JSON(http.StatusOK, gin.H{"message": "Task marked as completed successfully"})
+	for _, p := range []struct {
+		name   string
+		target **int
+	}{{"min_points", &query.MinPoints}, {"max_points", &query.MaxPoints}} {
+		if v := c.Query(p.name); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil {
+				return query, errors.New(p.name + " must be an integer")
+			}
+			*p.target = &n
+		}
+	}
+	if v := c.Query("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return query, errors.New("limit must be an integer")
+		}
+		query.Limit = n
+	}
+	return query, nil
 }
 
-// GetRandomAdventureTaskHandler 获取随机冒险任务处理函数
-func GetRandomAdventureTaskHandler(c *gin.Context) {
-	task, err := taskService.GetRandomAdventureTask()
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		return
+// ListUserTasksHandler 分页列出用户创建的任务，路径中的 userID 已由 RequireSelf 校验
+func ListUserTasksHandler(taskService *services.TaskService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		query, err := taskQuery(c)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		page, err := taskService.GetPersonalTasks(CurrentUser(c).ID, query)
+		if err != nil {
+			respondTaskError(c, err, "Failed to list tasks")
+			return
+		}
+		c.JSON(http.StatusOK, page)
 	}
-	c.JSON(http.StatusOK, task)
 }
 
-// CreateCombinationTaskHandler 创建组合任务处理函数
-func CreateCombinationTaskHandler(c *gin.Context) {
-	var combinationTask models.CombinationTask
-	if err := c.BindJSON(&combinationTask); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request payload"})
-		return
-	}
+// ListDueTasksHandler 按用户时区列出今天截止、即将截止或已逾期的未完成任务，
+// upcoming 视图的 days 参数指定包含明天起的多少天
+func ListDueTasksHandler(taskService *services.TaskService, view string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		query, err := taskQuery(c)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		days := 0
+		if v := c.Query("days"); v != "" {
+			if days, err = strconv.Atoi(v); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "days must be an integer"})
+				return
+			}
+		}
 
-	// 确保使用 taskService 实例调用 CreateCombinationTask 方法
-	err := taskService.CreateCombinationTask(combinationTask.UserID, combinationTask.Title, combinationTask.Description, combinationTask.SubTasks)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		return
+		page, err := taskService.ListDueTasks(CurrentUser(c).ID, view, days, query)
+		if err != nil {
+			respondTaskError(c, err, "Failed to list tasks")
+			return
+		}
+		c.JSON(http.StatusOK, page)
 	}
+}
+
+// ListTeamTasksHandler 分页列出团队任务，只有团队成员可以查看
+func ListTeamTasksHandler(taskService *services.TaskService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		teamID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid team ID"})
+			return
+		}
+		query, err := taskQuery(c)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
 
-	c.JSON(http.StatusCreated, combinationTask)
+		member, err := taskService.IsTeamMember(uint(teamID), CurrentUser(c).ID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list tasks"})
+			return
+		}
+		if !member {
+			respondTaskError(c, services.ErrNotTeamMember, "")
+			return
+		}
+
+		page, err := taskService.GetTeamTasks(uint(teamID), query)
+		if err != nil {
+			respondTaskError(c, err, "Failed to list tasks")
+			return
+		}
+		c.JSON(http.StatusOK, page)
+	}
 }
 
-// DeleteCompletedTasksHandler 删除已完成任务处理函数
-func DeleteCompletedTasksHandler(c *gin.Context) {
-	// 使用 taskService 实例调用 DeleteCompletedTasks 方法
-	err := taskService.DeleteCompletedTasks()
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		return
+// GetRandomDailyTaskHandler 获取每日打卡任务处理函数
+func GetRandomDailyTaskHandler(taskService *services.TaskService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		// 路径中的 userID 已由 RequireSelf 校验，直接使用当前登录用户
+		userID := CurrentUser(c).ID
+
+		// 调用服务获取任务
+		task, err := taskService.GetRandomDailyTask(userID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching task"})
+			return
+		}
+
+		// 检查是否找到了任务
+		if task == nil {
+			c.JSON(http.StatusOK, gin.H{"message": "No tasks found for the user"})
+			return
+		}
+
+		// 成功找到任务，返回给客户端
+		c.JSON(http.StatusOK, task)
 	}
+}
 
-	c.JSON(http.StatusOK, gin.H{"message": "Completed tasks deleted"})
+// MarkTaskCompletedHandler 打卡任务完成处理函数
+func MarkTaskCompletedHandler(taskService *services.TaskService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		// 从请求路径中提取 taskID
+		taskIDStr := c.Param("taskID")
+		taskID, err := strconv.ParseUint(taskIDStr, 10, 32)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid task ID"})
+			return
+		}
+
+		// 只能打卡自己有权访问的任务
+		if _, err := taskService.GetTaskForUser(uint(taskID), CurrentUser(c).ID); err != nil {
+			respondTaskError(c, err, "Failed to mark task as completed")
+			return
+		}
+
+		// 调用服务层方法来标记任务为完成
+		err = taskService.MarkTaskAsCompleted(uint(taskID))
+		if err != nil {
+			// 如果更新时遇到错误，向客户端返回错误信息
+			if errors.Is(err, services.ErrSubTasksIncomplete) {
+				respondTaskError(c, err, "")
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to mark task as completed"})
+			return
+		}
+
+		// 如果成功，向客户端返回成功信息
+		c.JSON(http.StatusOK, gin.H{"message": "Task marked as completed successfully"})
+	}
 }
 
-// CompleteTeamTaskHandler 完成团队任务处理函数
-func CompleteTeamTaskHandler(c *gin.Context) {
-	// 获取 taskID
-	taskID := c.Param("id")
-	id, err := strconv.ParseUint(taskID, 10, 64)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid task ID"})
-		return
+// GetRandomAdventureTaskHandler 从冒险任务池中随机抽取一个任务
+func GetRandomAdventureTaskHandler(taskService *services.TaskService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		task, err := taskService.GetRandomAdventureTask()
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if task == nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "No adventure tasks available"})
+			return
+		}
+		c.JSON(http.StatusOK, task)
 	}
+}
 
-	// 假设从请求中获取 level 参数
-	levelParam := c.Query("level")
-	level, err := strconv.Atoi(levelParam) // 将 level 参数从字符串转换为 int
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid level"})
-		return
+// CreateCombinationTaskHandler 创建组合任务处理函数
+func CreateCombinationTaskHandler(taskService *services.TaskService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var combinationTask models.CombinationTask
+		if err := c.BindJSON(&combinationTask); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request payload"})
+			return
+		}
+
+		// 组合任务归属于当前登录用户
+		combinationTask.UserID = CurrentUser(c).ID
+
+		task, err := taskService.CreateCombinationTask(combinationTask.UserID, combinationTask.Title, combinationTask.Description,
+			combinationTask.Category, combinationTask.SubTasks)
+		if err != nil {
+			if errors.Is(err, services.ErrInvalidCategory) || errors.Is(err, services.ErrInvalidTask) ||
+				errors.Is(err, services.ErrSubTaskPoints) {
+				respondTaskError(c, err, "")
+			} else {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			}
+			return
+		}
+
+		c.JSON(http.StatusCreated, task)
 	}
+}
 
-	// 假设 taskService 是之前初始化好的 *services.TaskService 实例
-	err = taskService.CompleteTeamTask(uint(id), level)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		return
+// DeleteCompletedTasksHandler 删除所有用户的已完成任务，仅管理员可用
+func DeleteCompletedTasksHandler(taskService *services.TaskService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		err := taskService.DeleteCompletedTasks()
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Completed tasks deleted"})
 	}
+}
 
-	c.JSON(http.StatusOK, gin.H{"message": "Team task completed"})
+// CompleteTeamTaskHandler 完成团队任务处理函数，只有团队成员可以完成
+func CompleteTeamTaskHandler(taskService *services.TaskService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		// 获取 taskID
+		taskID := c.Param("id")
+		id, err := strconv.ParseUint(taskID, 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid task ID"})
+			return
+		}
+
+		// 假设从请求中获取 level 参数
+		levelParam := c.Query("level")
+		level, err := strconv.Atoi(levelParam) // 将 level 参数从字符串转换为 int
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid level"})
+			return
+		}
+
+		if _, err := taskService.GetTaskForUser(uint(id), CurrentUser(c).ID); err != nil {
+			respondTaskError(c, err, "Failed to complete team task")
+			return
+		}
+
+		err = taskService.CompleteTeamTask(uint(id), level)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Team task completed"})
+	}
 }
@@ -55,6 +55,9 @@ func JoinTeamHandler(c *gin.Context) {
 		return
 	}
 
+	// 只能以当前登录用户的身份加入团队
+	member.UserID = CurrentUser(c).ID
+
 	// 创建 TeamService 的实例
 	teamService := services.NewTeamService(db)
 
@@ -0,0 +1,112 @@
+package handlers
+
+import (
+	services "app/internal/app/service"
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// respondTOTPError 把两步验证相关的错误转换为对应的状态码
+func respondTOTPError(c *gin.Context, err error, message string) {
+	if abortIfRateLimited(c, err) {
+		return
+	}
+	switch {
+	case services.IsTOTPError(err):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrWrongPassword), errors.Is(err, services.ErrUserBanned), errors.Is(err, services.ErrUserDeleted):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrInvalidMFAToken):
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": message})
+	}
+}
+
+// SetupTOTPHandler 开始绑定验证器，返回密钥和供扫码的 otpauth:// 地址
+func SetupTOTPHandler(authService *services.AuthService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		secret, uri, err := authService.BeginTOTPEnrollment(CurrentUser(c).ID)
+		if err != nil {
+			respondTOTPError(c, err, "Failed to start two-factor enrollment")
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"secret": secret, "otpauth_uri": uri})
+	}
+}
+
+// ConfirmTOTPHandler 用第一个验证码确认绑定，返回只显示一次的恢复码
+func ConfirmTOTPHandler(authService *services.AuthService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req struct {
+			Code string `json:"code"`
+		}
+		if err := c.BindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request payload"})
+			return
+		}
+
+		codes, err := authService.ConfirmTOTPEnrollment(CurrentUser(c).ID, req.Code)
+		if err != nil {
+			respondTOTPError(c, err, "Failed to enable two-factor authentication")
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"message":        "Two-factor authentication enabled. Store the recovery codes in a safe place, they are shown only once.",
+			"recovery_codes": codes,
+		})
+	}
+}
+
+// DisableTOTPHandler 校验验证码、恢复码或密码后关闭两步验证
+func DisableTOTPHandler(authService *services.AuthService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req struct {
+			Password string `json:"password"`
+			Code     string `json:"code"`
+		}
+		if err := c.BindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request payload"})
+			return
+		}
+
+		if err := authService.DisableTOTP(CurrentUser(c).ID, req.Password, req.Code); err != nil {
+			respondTOTPError(c, err, "Failed to disable two-factor authentication")
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Two-factor authentication disabled"})
+	}
+}
+
+// LoginTOTPHandler 登录第二步：提交 /login 返回的 mfa_token 和验证码或恢复码，成功后签发令牌
+func LoginTOTPHandler(authService *services.AuthService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req struct {
+			MFAToken string `json:"mfa_token"`
+			Code     string `json:"code"`
+		}
+		if err := c.BindJSON(&req); err != nil || req.MFAToken == "" || req.Code == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request payload"})
+			return
+		}
+
+		user, err := authService.CompleteMFAChallenge(req.MFAToken, req.Code)
+		if err != nil {
+			respondTOTPError(c, err, "Failed to login")
+			return
+		}
+
+		tokens, err := authService.CreateSession(user.ID, clientInfo(c))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create session"})
+			return
+		}
+
+		c.JSON(http.StatusOK, tokens)
+	}
+}
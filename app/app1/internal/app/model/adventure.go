@@ -0,0 +1,14 @@
+package models
+
+import (
+	"gorm.io/gorm"
+)
+
+// AdventureTask 冒险任务池中的任务，由管理员维护，用户随机抽取
+type AdventureTask struct {
+	gorm.Model
+	Title       string `json:"title" gorm:"size:255"` // 任务标题
+	Description string `json:"description"`           // 任务描述
+	Points      int    `json:"points"`                // 任务积分
+	Active      bool   `json:"active"`                // 是否参与随机抽取
+}
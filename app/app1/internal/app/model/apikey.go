@@ -0,0 +1,20 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// APIKey 个人 API key，供脚本调用任务接口，只保存哈希值
+type APIKey struct {
+	gorm.Model
+	UserID     uint       `gorm:"index"`               // 用户ID，用于关联用户
+	Name       string     `gorm:"size:100"`            // 名称，便于用户区分用途
+	Prefix     string     `gorm:"size:16"`             // key 的前几位，列表中用于识别
+	KeyHash    string     `gorm:"size:64;uniqueIndex"` // key 的哈希
+	Scopes     string     `gorm:"size:255"`            // 权限范围，逗号分隔
+	LastUsedAt *time.Time // 最近一次使用时间
+	ExpiresAt  time.Time  // 过期时间
+	RevokedAt  *time.Time // 撤销时间，为空表示有效
+}
@@ -0,0 +1,25 @@
+package models
+
+import (
+	"gorm.io/gorm"
+)
+
+// AuditLog 管理员对用户的操作记录，只增不改
+type AuditLog struct {
+	gorm.Model
+	ActorID      uint   `gorm:"index" json:"actor_id"`       // 操作人
+	TargetUserID uint   `gorm:"index" json:"target_user_id"` // 被操作的用户
+	Action       string `gorm:"size:50;index" json:"action"` // 操作类型
+	Reason       string `gorm:"size:500" json:"reason"`      // 操作原因
+	Detail       string `gorm:"type:text" json:"detail"`     // 操作内容，JSON 格式
+}
+
+// ExperienceLog 用户经验变动记录
+type ExperienceLog struct {
+	gorm.Model
+	UserID uint   `gorm:"index" json:"user_id"`   // 用户ID
+	Track  string `gorm:"size:20" json:"track"`   // 经验类型，见 services.Track* 常量
+	Delta  int    `json:"delta"`                  // 变动值，可为负数
+	Source string `gorm:"size:50" json:"source"`  // 来源，例如 admin、task
+	Reason string `gorm:"size:500" json:"reason"` // 说明
+}
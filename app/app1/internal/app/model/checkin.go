@@ -0,0 +1,17 @@
+package models
+
+import (
+	"gorm.io/gorm"
+)
+
+// CheckIn 周期任务某个发生日的打卡记录，每个周期任务每天最多一条。
+// 除了当天完成，还可以用保护卡或积分补上错过的发生日
+type CheckIn struct {
+	gorm.Model
+	UserID          uint   `json:"user_id" gorm:"index"`                                  // 用户ID，用于关联用户
+	RecurringTaskID uint   `json:"recurring_task_id" gorm:"uniqueIndex:idx_check_in_day"` // 周期任务ID
+	Date            string `json:"date" gorm:"size:10;uniqueIndex:idx_check_in_day"`      // 打卡对应的发生日，格式 2006-01-02
+	TaskID          uint   `json:"task_id"`                                               // 完成的任务实例
+	Kind            string `json:"kind" gorm:"size:20"`                                   // 打卡方式，见 services.CheckIn* 常量
+	Cost            int    `json:"cost" gorm:"default:0"`                                 // 补打卡花费的积分
+}
@@ -0,0 +1,24 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// RecoveryCode 两步验证恢复码，只能使用一次
+type RecoveryCode struct {
+	gorm.Model
+	UserID   uint       `gorm:"index"`   // 用户ID，用于关联用户
+	CodeHash string     `gorm:"size:64"` // 恢复码哈希
+	UsedAt   *time.Time // 使用时间，为空表示尚未使用
+}
+
+// MFAChallenge 密码验证通过、等待第二步验证的登录请求
+type MFAChallenge struct {
+	gorm.Model
+	UserID    uint      `gorm:"index"`               // 用户ID，用于关联用户
+	TokenHash string    `gorm:"size:64;uniqueIndex"` // 登录凭证哈希
+	ExpiresAt time.Time // 过期时间
+	Attempts  int       // 已经输错的次数
+}
@@ -0,0 +1,27 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Notification 站内通知，目前用于任务提醒和逾期通知
+type Notification struct {
+	gorm.Model
+	UserID uint       `json:"user_id" gorm:"index"` // 接收通知的用户
+	Kind   string     `json:"kind" gorm:"size:20"`  // 通知类型，见 services.Notification* 常量
+	Title  string     `json:"title"`                // 按用户语言生成的标题
+	TaskID uint       `json:"task_id"`              // 相关的任务
+	ReadAt *time.Time `json:"read_at"`              // 已读时间，为空表示未读
+}
+
+// TaskReminder 计划发送的任务提醒，修改任务的截止时间或提醒设置时重新生成
+type TaskReminder struct {
+	ID       uint       `gorm:"primaryKey"`
+	TaskID   uint       `gorm:"index"`
+	UserID   uint       // 接收提醒的用户
+	Kind     string     `gorm:"size:20"` // 截止前提醒或逾期通知，与通知类型相同
+	RemindAt time.Time  `gorm:"index"`   // 计划发送时间
+	SentAt   *time.Time // 发送时间，为空表示尚未发送
+}
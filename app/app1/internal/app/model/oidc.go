@@ -0,0 +1,28 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// OIDCIdentity 用户绑定的第三方登录身份，同一个用户可以绑定多个
+type OIDCIdentity struct {
+	gorm.Model
+	UserID      uint       `gorm:"index" json:"-"`                                       // 用户ID，用于关联用户
+	Provider    string     `gorm:"size:50;uniqueIndex:idx_oidc_subject" json:"provider"` // 配置中的 provider 名称
+	Subject     string     `gorm:"size:255;uniqueIndex:idx_oidc_subject" json:"-"`       // ID token 中的 sub
+	Email       string     `json:"email"`                                                // 绑定时 provider 返回的邮箱
+	LastLoginAt *time.Time `json:"last_login_at"`                                        // 最近一次通过该身份登录的时间
+}
+
+// OIDCLoginState 跳转到 provider 前保存的 state，回调时校验，只能使用一次
+type OIDCLoginState struct {
+	gorm.Model
+	StateHash    string `gorm:"size:64;uniqueIndex"` // state 的哈希
+	Provider     string `gorm:"size:50"`
+	Nonce        string `gorm:"size:64"`  // 校验 ID token 中的 nonce
+	CodeVerifier string `gorm:"size:128"` // PKCE code_verifier
+	UserID       uint   // 已登录用户绑定新身份时的用户ID，登录时为 0
+	ExpiresAt    time.Time
+}
@@ -0,0 +1,19 @@
+package models
+
+import (
+	"gorm.io/gorm"
+)
+
+// RecurringTask 周期任务的定义，调度器按 RRule 在用户时区的每个发生日生成一个 Task 实例
+type RecurringTask struct {
+	gorm.Model
+	UserID         uint   `json:"user_id" gorm:"index"`               // 用户ID，用于关联用户
+	Title          string `json:"title" gorm:"size:255"`              // 任务标题
+	Description    string `json:"description"`                        // 任务描述
+	Points         int    `json:"points"`                             // 每次完成的积分
+	Category       string `json:"category" gorm:"size:20"`            // 分类，同 Task.Category
+	RRule          string `json:"rrule" gorm:"column:rrule;size:255"` // RFC 5545 RRULE 子集，例如 FREQ=WEEKLY;BYDAY=MO,WE
+	StartDate      string `json:"start_date" gorm:"size:10"`          // 第一次发生的日期（DTSTART），格式 2006-01-02
+	Active         bool   `json:"active"`                             // 是否继续生成实例
+	LastOccurrence string `json:"last_occurrence" gorm:"size:10"`     // 最近一次检查过的日期，防止重复生成
+}
@@ -0,0 +1,21 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Session 登录会话，访问令牌和刷新令牌只保存哈希值
+type Session struct {
+	gorm.Model
+	UserID           uint       `gorm:"index"`               // 用户ID，用于关联用户
+	AccessTokenHash  string     `gorm:"size:64;uniqueIndex"` // 访问令牌哈希
+	AccessExpiresAt  time.Time  // 访问令牌过期时间
+	RefreshTokenHash string     `gorm:"size:64;uniqueIndex"` // 刷新令牌哈希
+	RefreshExpiresAt time.Time  // 刷新令牌过期时间
+	RevokedAt        *time.Time // 注销时间，为空表示会话有效
+	Device           string     `gorm:"size:255"` // 登录时的 User-Agent
+	IP               string     `gorm:"size:64"`  // 最近一次使用的 IP
+	LastSeenAt       time.Time  // 最近一次使用时间
+}
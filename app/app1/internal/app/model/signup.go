@@ -0,0 +1,16 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// PendingSignup 已发送验证码、尚未完成验证的注册信息
+type PendingSignup struct {
+	gorm.Model
+	Email        string    `gorm:"size:255;uniqueIndex"` // 注册邮箱
+	Username     string    // 用户名
+	PasswordHash string    // 已经加密的密码
+	ExpiresAt    time.Time // 过期后需要重新提交注册
+}
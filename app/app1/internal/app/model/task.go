@@ -1,20 +1,40 @@
 package models
 
 import (
+	"time"
+
 	"gorm.io/gorm"
 )
 
 type Task struct {
 	gorm.Model
 	ID           uint             `json:"id"`
-	UserID       uint             `json:"user_id"`      // 用户ID，用于关联用户
-	TeamID       uint             `json:"team_id"`      // 团队ID，用于关联团队
-	Title        string           `json:"title"`        // 任务标题
-	Description  string           `json:"description"`  // 任务描述
-	Points       int              `json:"points"`       // 任务积分
-	Completed    bool             `json:"completed"`    // 是否已完成
-	TaskType     string           `json:"task_type"`    // 任务类型，可以是 "personal" 或 "team"
-	Contributors map[uint]float64 `json:"contributors"` // 参与者，key为用户ID，value为贡献度
+	UserID       uint             `json:"user_id"`                             // 用户ID，用于关联用户
+	TeamID       uint             `json:"team_id"`                             // 团队ID，用于关联团队
+	Title        string           `json:"title"`                               // 任务标题
+	Description  string           `json:"description"`                         // 任务描述
+	Points       int              `json:"points"`                              // 任务积分
+	Completed    bool             `json:"completed"`                           // 是否已完成
+	CompletedAt  *time.Time       `json:"completed_at" gorm:"index"`           // 完成时间，未完成时为空
+	TaskType     string           `json:"task_type"`                           // 任务类型，可以是 "personal" 或 "team"
+	Contributors map[uint]float64 `json:"contributors" gorm:"serializer:json"` // 参与者，key为用户ID，value为贡献度
+	Tags         []string         `json:"tags" gorm:"-"`                       // 标签，保存在 TaskTag 表中
+	Category     string           `json:"category" gorm:"size:20"`             // 分类：self_improvement、work、habit 或 todo，为空视为 todo
+	Rewarded     bool             `json:"rewarded" gorm:"default:false"`       // 完成奖励是否已发放，取消完成后再次完成不会重复发放
+	RecurrenceID uint             `json:"recurrence_id" gorm:"index"`          // 由周期任务生成时为 RecurringTask 的 ID
+	Occurrence   string           `json:"occurrence" gorm:"size:10"`           // 周期任务实例对应的日期，格式 2006-01-02
+	DueAt        *time.Time       `json:"due_at" gorm:"index"`                 // 截止时间，为空表示没有截止时间
+	AllDay       bool             `json:"all_day" gorm:"default:false"`        // 只有截止日期，DueAt 为用户时区当天的 23:59:59
+	Reminders    []int            `json:"reminders" gorm:"serializer:json"`    // 截止前多少分钟发送提醒
+	Overdue      bool             `json:"overdue" gorm:"-"`                    // 未完成且已过截止时间，读取时计算
+	SubTasks     []SubTask        `json:"subtasks,omitempty" gorm:"-"`         // 组合任务的子任务，只在创建组合任务时返回
+}
+
+// TaskTag 任务标签，单独成表以便按标签筛选任务
+type TaskTag struct {
+	ID     uint   `gorm:"primaryKey"`
+	TaskID uint   `gorm:"index"`
+	Tag    string `gorm:"size:32;index"`
 }
 
 type SubTask struct {
@@ -24,11 +44,13 @@ type SubTask struct {
 	Points      int    `json:"points"`      // 子任务积分
 	Completed   bool   `json:"completed"`   // 是否已完成
 	TaskID      uint   `json:"task_id"`     // 任务ID，用于关联父任务
+	Position    int    `json:"position"`    // 在父任务中的顺序，从 1 开始
 }
 
 type CombinationTask struct {
 	UserID      uint      `json:"userId"` // 假设使用uint类型的ID
 	Title       string    `json:"title"`
 	Description string    `json:"description"`
+	Category    string    `json:"category"`
 	SubTasks    []SubTask `json:"subTasks"`
 }
@@ -6,7 +6,7 @@ type Team struct {
 	gorm.Model
 	Name       string // 团队名称
 	Invitation string // 邀请码
-	Members    []User // 团队成员，可以根据具体需求修改
+	Members    []User `gorm:"many2many:user_teams"` // 团队成员，通过 UserTeam 关联
 }
 
 type UserTeam struct {
@@ -16,10 +16,10 @@ type UserTeam struct {
 
 type TeamMember struct {
 	gorm.Model
-	UserID       uint   // 用户ID，用于关联用户
-	TeamID       uint   // 团队ID，用于关联团队
-	Position     string // 职位信息
-	Contributors map[uint]float64
-	Comments     map[uint]string
-	Invitation   interface{}
+	UserID       uint             // 用户ID，用于关联用户
+	TeamID       uint             // 团队ID，用于关联团队
+	Position     string           // 职位信息
+	Contributors map[uint]float64 `gorm:"serializer:json"`
+	Comments     map[uint]string  `gorm:"serializer:json"` // 收到的评论，key为评论人的成员ID
+	Invitation   interface{}      `gorm:"-"`               // 仅用于加入团队时接收邀请码
 }
@@ -1,21 +1,39 @@
 package models
 
 import (
+	"time"
+
 	"github.com/jinzhu/gorm"
 )
 
 type User struct {
 	gorm.Model
-	Email              string `gorm:"uniqueIndex"`
-	Username           string
-	Password           string
-	VerificationCode   string // 添加验证码字段
-	DailyTask          string // 每日打卡任务
-	DailyTaskID        uint
-	Level              int
-	Experience         int // 用户经验值
-	SelfImprovementExp int // 自我提升经验
-	WorkExp            int // 工作事务经验
-	HabitExp           int // 习惯养成经验
-	TodoExp            int // 待办杂事经验
+	Email               string `gorm:"uniqueIndex"`
+	Username            string `gorm:"uniqueIndex"`
+	Password            string `json:"-"`
+	VerificationCode    string `json:"-"` // 添加验证码字段
+	DailyTask           string // 每日打卡任务，已由 RecurringTask 取代
+	DailyTaskID         uint
+	Level               int
+	Experience          int        // 用户经验值
+	SelfImprovementExp  int        // 自我提升经验
+	WorkExp             int        // 工作事务经验
+	HabitExp            int        // 习惯养成经验
+	TodoExp             int        // 待办杂事经验
+	Locale              string     // 语言偏好，zh-CN 或 en，决定邮件语言
+	AvatarURL           string     // 头像地址
+	Bio                 string     // 个人简介
+	Timezone            string     // IANA 时区，例如 Asia/Shanghai
+	TOTPEnabled         bool       // 是否已开启两步验证
+	TOTPSecret          string     `json:"-"`                    // 两步验证密钥，Base32 编码
+	TOTPPendingSecret   string     `json:"-"`                    // 绑定中尚未确认的密钥
+	TOTPLastCounter     int64      `json:"-"`                    // 最近一次使用的 TOTP 时间步，防止验证码重放
+	Role                string     `gorm:"size:20;default:user"` // 角色：user、moderator 或 admin
+	BannedAt            *time.Time // 封禁时间，为空表示未封禁
+	BanReason           string     // 封禁原因
+	BannedUntil         *time.Time // 暂停到期时间，为空表示永久封禁
+	DeletionScheduledAt *time.Time // 申请注销后计划删除数据的时间，为空表示未申请
+	StreakFreezes       int        `gorm:"default:0"` // 持有的连续打卡保护卡数量
+	Points              int        `gorm:"default:0"` // 可用积分，只由任务和打卡奖励获得，用于购买保护卡和补打卡
+	DigestSentAt        *time.Time // 最近一次发送任务周报的时间，为空表示还没有发送过
 }
@@ -0,0 +1,18 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// VerificationCode 邮件验证码，只保存验证码的哈希值
+type VerificationCode struct {
+	gorm.Model
+	Email      string     `gorm:"size:255;index:idx_verification_email_purpose"` // 接收验证码的邮箱
+	Purpose    string     `gorm:"size:32;index:idx_verification_email_purpose"`  // 用途：register、login、reset
+	CodeHash   string     `gorm:"size:64"`                                       // 验证码哈希
+	ExpiresAt  time.Time  // 过期时间
+	Attempts   int        // 已经输错的次数
+	ConsumedAt *time.Time // 使用时间，为空表示尚未使用
+}
@@ -0,0 +1,313 @@
+package services
+
+import (
+	models "app/internal/app/model"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	// AccountDeletionGracePeriod 申请注销后保留数据的时间，期间可以撤销
+	AccountDeletionGracePeriod = 30 * 24 * time.Hour
+	// AccountPurgeInterval 检查到期注销账号的间隔
+	AccountPurgeInterval = time.Hour
+)
+
+var (
+	ErrDeletionNotScheduled = errors.New("account deletion is not scheduled")
+	ErrDeletionScheduled    = errors.New("account deletion is already scheduled")
+)
+
+// TeamMembershipExport 导出的团队成员关系
+type TeamMembershipExport struct {
+	MemberID uint      `json:"member_id"`
+	TeamID   uint      `json:"team_id"`
+	TeamName string    `json:"team_name"`
+	Position string    `json:"position"`
+	JoinedAt time.Time `json:"joined_at"`
+}
+
+// CommentExport 导出的团队评论，包括收到的和写给别人的
+type CommentExport struct {
+	TeamID       uint   `json:"team_id"`
+	FromMemberID uint   `json:"from_member_id"`
+	ToMemberID   uint   `json:"to_member_id"`
+	Direction    string `json:"direction"` // received 或 written
+	Comment      string `json:"comment"`
+}
+
+// AccountExport 用户的全部个人数据
+type AccountExport struct {
+	User       *models.User           `json:"user"`
+	Tasks      []models.Task          `json:"tasks"`
+	SubTasks   []models.SubTask       `json:"sub_tasks"`
+	Recurring  []models.RecurringTask `json:"recurring_tasks"`
+	CheckIns   []models.CheckIn       `json:"check_ins"`
+	Notices    []models.Notification  `json:"notifications"`
+	Teams      []TeamMembershipExport `json:"teams"`
+	Comments   []CommentExport        `json:"comments"`
+	Experience []models.ExperienceLog `json:"experience_logs"`
+}
+
+// ExportAccount 收集用户的资料、任务、子任务、周期任务、打卡记录、通知、团队、评论和经验记录
+func (s *AuthService) ExportAccount(userID uint) (*AccountExport, error) {
+	user, err := s.GetUser(userID)
+	if err != nil {
+		return nil, err
+	}
+	export := &AccountExport{User: user}
+
+	if err := s.db.Where("user_id = ?", userID).Order("id").Find(&export.Tasks).Error; err != nil {
+		return nil, err
+	}
+	if len(export.Tasks) > 0 {
+		taskIDs := make([]uint, len(export.Tasks))
+		tasks := make([]*models.Task, len(export.Tasks))
+		for i := range export.Tasks {
+			taskIDs[i] = export.Tasks[i].ID
+			tasks[i] = &export.Tasks[i]
+		}
+		if err := loadTaskDetails(s.db, tasks); err != nil {
+			return nil, err
+		}
+		if err := s.db.Where("task_id IN ?", taskIDs).Order("id").Find(&export.SubTasks).Error; err != nil {
+			return nil, err
+		}
+	}
+
+	var members []models.TeamMember
+	if err := s.db.Where("user_id = ?", userID).Order("id").Find(&members).Error; err != nil {
+		return nil, err
+	}
+	memberIDs := make(map[uint]bool)
+	teamIDs := make([]uint, 0, len(members))
+	for _, member := range members {
+		memberIDs[member.ID] = true
+		teamIDs = append(teamIDs, member.TeamID)
+	}
+
+	teamNames := make(map[uint]string)
+	var teammates []models.TeamMember
+	if len(teamIDs) > 0 {
+		var teams []models.Team
+		if err := s.db.Where("id IN ?", teamIDs).Find(&teams).Error; err != nil {
+			return nil, err
+		}
+		for _, team := range teams {
+			teamNames[team.ID] = team.Name
+		}
+		if err := s.db.Where("team_id IN ? AND user_id <> ?", teamIDs, userID).Find(&teammates).Error; err != nil {
+			return nil, err
+		}
+	}
+
+	for _, member := range members {
+		export.Teams = append(export.Teams, TeamMembershipExport{
+			MemberID: member.ID,
+			TeamID:   member.TeamID,
+			TeamName: teamNames[member.TeamID],
+			Position: member.Position,
+			JoinedAt: member.CreatedAt,
+		})
+		for from, comment := range member.Comments {
+			export.Comments = append(export.Comments, CommentExport{
+				TeamID: member.TeamID, FromMemberID: from, ToMemberID: member.ID, Direction: "received", Comment: comment,
+			})
+		}
+	}
+	for _, teammate := range teammates {
+		for from, comment := range teammate.Comments {
+			if memberIDs[from] {
+				export.Comments = append(export.Comments, CommentExport{
+					TeamID: teammate.TeamID, FromMemberID: from, ToMemberID: teammate.ID, Direction: "written", Comment: comment,
+				})
+			}
+		}
+	}
+
+	if err := s.db.Where("user_id = ?", userID).Order("id").Find(&export.Recurring).Error; err != nil {
+		return nil, err
+	}
+	if err := s.db.Where("user_id = ?", userID).Order("id").Find(&export.CheckIns).Error; err != nil {
+		return nil, err
+	}
+	if err := s.db.Where("user_id = ?", userID).Order("id").Find(&export.Notices).Error; err != nil {
+		return nil, err
+	}
+	if err := s.db.Where("user_id = ?", userID).Order("id").Find(&export.Experience).Error; err != nil {
+		return nil, err
+	}
+	return export, nil
+}
+
+// ScheduleAccountDeletion 校验密码后申请注销账号，宽限期结束后删除数据；
+// 只通过第三方登录注册的用户没有密码，不需要校验。申请后所有设备退出登录，宽限期内重新登录可以撤销
+func (s *AuthService) ScheduleAccountDeletion(userID uint, password string) (*models.User, error) {
+	user, err := s.GetUser(userID)
+	if err != nil {
+		return nil, err
+	}
+	if user.DeletionScheduledAt != nil {
+		return nil, ErrDeletionScheduled
+	}
+	if user.Password != "" {
+		if ok, _ := verifyPassword(password, user.Password); !ok {
+			return nil, ErrWrongPassword
+		}
+	}
+
+	scheduled := time.Now().Add(AccountDeletionGracePeriod)
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(user).Update("deletion_scheduled_at", &scheduled).Error; err != nil {
+			return err
+		}
+		return revokeUserSessions(tx, userID)
+	})
+	if err != nil {
+		return nil, err
+	}
+	user.DeletionScheduledAt = &scheduled
+	return user, nil
+}
+
+// CancelAccountDeletion 宽限期内撤销注销申请
+func (s *AuthService) CancelAccountDeletion(userID uint) (*models.User, error) {
+	user, err := s.GetUser(userID)
+	if err != nil {
+		return nil, err
+	}
+	if user.DeletionScheduledAt == nil {
+		return nil, ErrDeletionNotScheduled
+	}
+	if err := s.db.Model(user).Update("deletion_scheduled_at", nil).Error; err != nil {
+		return nil, err
+	}
+	user.DeletionScheduledAt = nil
+	return user, nil
+}
+
+// PurgeDeletedAccounts 删除宽限期已过的账号数据，单个账号失败只记录日志
+func (s *AuthService) PurgeDeletedAccounts(now time.Time) error {
+	var userIDs []uint
+	if err := s.db.Model(&models.User{}).
+		Where("deletion_scheduled_at IS NOT NULL AND deletion_scheduled_at <= ?", now).
+		Pluck("id", &userIDs).Error; err != nil {
+		return err
+	}
+
+	for _, userID := range userIDs {
+		if err := s.db.Transaction(func(tx *gorm.DB) error {
+			return purgeAccount(tx, userID, now)
+		}); err != nil {
+			log.Printf("purge account %d: %v", userID, err)
+		}
+	}
+	return nil
+}
+
+// purgeAccount 删除个人任务、团队成员关系、评论和登录凭证，用户记录匿名化保留，
+// 以免审计日志和团队任务中的引用失效
+func purgeAccount(tx *gorm.DB, userID uint, now time.Time) error {
+	// 周期任务、打卡记录、通知、提醒、个人任务及其子任务直接删除，团队任务保留给团队，只去掉归属和贡献记录
+	if err := tx.Unscoped().Where("user_id = ?", userID).Delete(&models.RecurringTask{}).Error; err != nil {
+		return err
+	}
+	if err := tx.Unscoped().Where("user_id = ?", userID).Delete(&models.CheckIn{}).Error; err != nil {
+		return err
+	}
+	if err := tx.Unscoped().Where("user_id = ?", userID).Delete(&models.Notification{}).Error; err != nil {
+		return err
+	}
+	if err := tx.Where("user_id = ?", userID).Delete(&models.TaskReminder{}).Error; err != nil {
+		return err
+	}
+	var personalTaskIDs []uint
+	if err := tx.Model(&models.Task{}).Where("user_id = ? AND team_id = 0", userID).
+		Pluck("id", &personalTaskIDs).Error; err != nil {
+		return err
+	}
+	if len(personalTaskIDs) > 0 {
+		if err := tx.Unscoped().Where("task_id IN ?", personalTaskIDs).Delete(&models.SubTask{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("task_id IN ?", personalTaskIDs).Delete(&models.TaskTag{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Unscoped().Where("id IN ?", personalTaskIDs).Delete(&models.Task{}).Error; err != nil {
+			return err
+		}
+	}
+	if err := tx.Model(&models.Task{}).Where("user_id = ? AND team_id <> 0", userID).
+		Update("user_id", 0).Error; err != nil {
+		return err
+	}
+
+	// 团队成员关系，以及写给队友的评论
+	var members []models.TeamMember
+	if err := tx.Where("user_id = ?", userID).Find(&members).Error; err != nil {
+		return err
+	}
+	for _, member := range members {
+		var teammates []models.TeamMember
+		if err := tx.Where("team_id = ? AND id <> ?", member.TeamID, member.ID).Find(&teammates).Error; err != nil {
+			return err
+		}
+		for i := range teammates {
+			if _, ok := teammates[i].Comments[member.ID]; !ok {
+				continue
+			}
+			delete(teammates[i].Comments, member.ID)
+			if err := tx.Model(&teammates[i]).Update("comments", teammates[i].Comments).Error; err != nil {
+				return err
+			}
+		}
+
+		var teamTasks []models.Task
+		if err := tx.Where("team_id = ?", member.TeamID).Find(&teamTasks).Error; err != nil {
+			return err
+		}
+		for i := range teamTasks {
+			if _, ok := teamTasks[i].Contributors[userID]; !ok {
+				continue
+			}
+			delete(teamTasks[i].Contributors, userID)
+			if err := tx.Model(&teamTasks[i]).Select("contributors").Updates(&teamTasks[i]).Error; err != nil {
+				return err
+			}
+		}
+	}
+	if err := tx.Unscoped().Where("user_id = ?", userID).Delete(&models.TeamMember{}).Error; err != nil {
+		return err
+	}
+	if err := tx.Where("user_id = ?", userID).Delete(&models.UserTeam{}).Error; err != nil {
+		return err
+	}
+
+	// 登录凭证、第三方登录身份和经验记录
+	for _, model := range []interface{}{&models.Session{}, &models.APIKey{}, &models.RecoveryCode{},
+		&models.MFAChallenge{}, &models.OIDCIdentity{}, &models.OIDCLoginState{}, &models.ExperienceLog{}} {
+		if err := tx.Unscoped().Where("user_id = ?", userID).Delete(model).Error; err != nil {
+			return err
+		}
+	}
+
+	// 用户记录匿名化
+	return tx.Model(&models.User{}).Where("id = ?", userID).Updates(map[string]interface{}{
+		"email":                 fmt.Sprintf("deleted-%d@deleted.invalid", userID),
+		"username":              fmt.Sprintf("deleted-%d", userID),
+		"password":              "",
+		"verification_code":     "",
+		"daily_task":            "",
+		"avatar_url":            "",
+		"bio":                   "",
+		"totp_enabled":          false,
+		"totp_secret":           "",
+		"totp_pending_secret":   "",
+		"deletion_scheduled_at": nil,
+		"deleted_at":            now,
+	}).Error
+}
@@ -0,0 +1,307 @@
+package services
+
+import (
+	models "app/internal/app/model"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// 审计日志中的操作类型
+const (
+	AuditSetRole          = "set_role"
+	AuditBan              = "ban"
+	AuditUnban            = "unban"
+	AuditSuspend          = "suspend"
+	AuditUnsuspend        = "unsuspend"
+	AuditResetPassword    = "reset_password"
+	AuditAdjustExperience = "adjust_experience"
+)
+
+const (
+	// DefaultAdminPageSize 用户搜索默认每页数量
+	DefaultAdminPageSize = 20
+	// MaxAdminPageSize 用户搜索每页最大数量
+	MaxAdminPageSize = 100
+	// MaxSuspensionDays 暂停最长天数，更长的处罚使用永久封禁
+	MaxSuspensionDays = 365
+)
+
+var (
+	ErrReasonRequired    = errors.New("reason is required")
+	ErrInvalidSuspension = errors.New("suspension must end in the future and last at most 365 days")
+	ErrUserAlreadyBanned = errors.New("user is permanently banned")
+	ErrUserNotSuspended  = errors.New("user is not suspended")
+	ErrInvalidAdjustment = errors.New("delta cannot be zero")
+)
+
+// IsAdminError 判断是否为管理操作的参数错误，handler 据此返回 400
+func IsAdminError(err error) bool {
+	for _, target := range []error{ErrInvalidRole, ErrBanSelf, ErrReasonRequired, ErrInvalidSuspension,
+		ErrUserAlreadyBanned, ErrUserNotSuspended, ErrInvalidAdjustment, ErrInvalidTrack,
+		ErrNegativeExperience, ErrWeakPassword} {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// writeAudit 在事务中写入审计日志
+func writeAudit(tx *gorm.DB, actorID, targetUserID uint, action, reason string, detail map[string]interface{}) error {
+	content := ""
+	if detail != nil {
+		b, err := json.Marshal(detail)
+		if err != nil {
+			return err
+		}
+		content = string(b)
+	}
+	return tx.Create(&models.AuditLog{
+		ActorID:      actorID,
+		TargetUserID: targetUserID,
+		Action:       action,
+		Reason:       reason,
+		Detail:       content,
+	}).Error
+}
+
+// escapeLike 转义 LIKE 查询中的通配符
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(s)
+}
+
+// SearchUsers 按邮箱或用户名模糊搜索用户，query 为空时列出全部用户
+func (s *AuthService) SearchUsers(query string, page, pageSize int) ([]models.User, int64, error) {
+	if page < 1 {
+		page = 1
+	}
+	if pageSize < 1 {
+		pageSize = DefaultAdminPageSize
+	}
+	if pageSize > MaxAdminPageSize {
+		pageSize = MaxAdminPageSize
+	}
+
+	db := s.db.Model(&models.User{})
+	if query = strings.TrimSpace(query); query != "" {
+		pattern := "%" + escapeLike(query) + "%"
+		db = db.Where("email LIKE ? OR username LIKE ?", pattern, pattern)
+	}
+
+	var total int64
+	if err := db.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	var users []models.User
+	if err := db.Order("id").Offset((page - 1) * pageSize).Limit(pageSize).Find(&users).Error; err != nil {
+		return nil, 0, err
+	}
+	return users, total, nil
+}
+
+// SuspendUser 暂停角色比操作者低的用户到指定时间，最长 MaxSuspensionDays 天，到期后自动恢复；
+// 暂停期间会话全部注销
+func (s *AuthService) SuspendUser(operatorID, userID uint, reason string, until time.Time) (*models.User, error) {
+	if operatorID == userID {
+		return nil, ErrBanSelf
+	}
+	reason = strings.TrimSpace(reason)
+	if reason == "" {
+		return nil, ErrReasonRequired
+	}
+	now := time.Now()
+	if !until.After(now) || until.After(now.AddDate(0, 0, MaxSuspensionDays)) {
+		return nil, ErrInvalidSuspension
+	}
+	user, err := s.GetUser(userID)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := s.checkOutranks(operatorID, user); err != nil {
+		return nil, err
+	}
+	if user.BannedAt != nil && user.BannedUntil == nil {
+		return nil, ErrUserAlreadyBanned
+	}
+	return s.restrictUser(operatorID, user, AuditSuspend, reason, &until)
+}
+
+// UnsuspendUser 提前结束暂停，永久封禁需要用 UnbanUser 解除
+func (s *AuthService) UnsuspendUser(operatorID, userID uint, reason string) (*models.User, error) {
+	user, err := s.GetUser(userID)
+	if err != nil {
+		return nil, err
+	}
+	if user.BannedAt == nil || user.BannedUntil == nil {
+		return nil, ErrUserNotSuspended
+	}
+	if _, err := s.checkOutranks(operatorID, user); err != nil {
+		return nil, err
+	}
+	return s.liftRestriction(operatorID, user, AuditUnsuspend, reason)
+}
+
+// restrictUser 封禁或暂停用户，注销会话并记录审计日志
+func (s *AuthService) restrictUser(operatorID uint, user *models.User, action, reason string, until *time.Time) (*models.User, error) {
+	now := time.Now()
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(user).Updates(map[string]interface{}{
+			"banned_at":    &now,
+			"ban_reason":   reason,
+			"banned_until": until,
+		}).Error; err != nil {
+			return err
+		}
+		if err := revokeUserSessions(tx, user.ID); err != nil {
+			return err
+		}
+		detail := map[string]interface{}{}
+		if until != nil {
+			detail["until"] = until
+		}
+		return writeAudit(tx, operatorID, user.ID, action, reason, detail)
+	})
+	if err != nil {
+		return nil, err
+	}
+	user.BannedAt = &now
+	user.BanReason = reason
+	user.BannedUntil = until
+	return user, nil
+}
+
+// liftRestriction 解除封禁或暂停并记录审计日志
+func (s *AuthService) liftRestriction(operatorID uint, user *models.User, action, reason string) (*models.User, error) {
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(user).Updates(map[string]interface{}{
+			"banned_at":    nil,
+			"ban_reason":   "",
+			"banned_until": nil,
+		}).Error; err != nil {
+			return err
+		}
+		return writeAudit(tx, operatorID, user.ID, action, strings.TrimSpace(reason), nil)
+	})
+	if err != nil {
+		return nil, err
+	}
+	user.BannedAt = nil
+	user.BanReason = ""
+	user.BannedUntil = nil
+	return user, nil
+}
+
+// AdminResetPassword 管理员重置用户密码：给出新密码时直接设置，否则向用户发送重置验证码；
+// 两种方式都会注销用户的全部会话
+func (s *AuthService) AdminResetPassword(operatorID, userID uint, newPassword, reason string) error {
+	reason = strings.TrimSpace(reason)
+	if reason == "" {
+		return ErrReasonRequired
+	}
+	user, err := s.GetUser(userID)
+	if err != nil {
+		return err
+	}
+
+	hashed := ""
+	if newPassword != "" {
+		if err := validatePassword(newPassword); err != nil {
+			return err
+		}
+		if hashed, err = hashPassword(newPassword); err != nil {
+			return err
+		}
+	}
+
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		if hashed != "" {
+			if err := tx.Model(user).Update("password", hashed).Error; err != nil {
+				return err
+			}
+		}
+		if err := revokeUserSessions(tx, userID); err != nil {
+			return err
+		}
+		return writeAudit(tx, operatorID, userID, AuditResetPassword, reason, map[string]interface{}{
+			"password_set": hashed != "",
+		})
+	})
+	if err != nil {
+		return err
+	}
+
+	if hashed == "" {
+		return s.issueCode(PurposeReset, user.Email, ResetTokenTTL)
+	}
+	return nil
+}
+
+// AdjustExperience 管理员调整用户的某项经验，必须填写原因
+func (s *AuthService) AdjustExperience(operatorID, userID uint, track string, delta int, reason string) (*models.User, error) {
+	reason = strings.TrimSpace(reason)
+	if reason == "" {
+		return nil, ErrReasonRequired
+	}
+	if delta == 0 {
+		return nil, ErrInvalidAdjustment
+	}
+	if _, ok := trackColumns[track]; !ok {
+		return nil, ErrInvalidTrack
+	}
+	user, err := s.GetUser(userID)
+	if err != nil {
+		return nil, err
+	}
+	before := trackValue(user, track)
+	if before+delta < 0 {
+		return nil, ErrNegativeExperience
+	}
+
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		if err := addExperience(tx, userID, track, delta, ExperienceSourceAdmin, reason); err != nil {
+			return err
+		}
+		return writeAudit(tx, operatorID, userID, AuditAdjustExperience, reason, map[string]interface{}{
+			"track":  track,
+			"delta":  delta,
+			"before": before,
+			"after":  before + delta,
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s.GetUser(userID)
+}
+
+// ListAuditLogs 按时间倒序列出审计日志，targetUserID 为 0 时列出全部用户的记录
+func (s *AuthService) ListAuditLogs(targetUserID uint, page, pageSize int) ([]models.AuditLog, int64, error) {
+	if page < 1 {
+		page = 1
+	}
+	if pageSize < 1 {
+		pageSize = DefaultAdminPageSize
+	}
+	if pageSize > MaxAdminPageSize {
+		pageSize = MaxAdminPageSize
+	}
+
+	db := s.db.Model(&models.AuditLog{})
+	if targetUserID != 0 {
+		db = db.Where("target_user_id = ?", targetUserID)
+	}
+
+	var total int64
+	if err := db.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	var logs []models.AuditLog
+	if err := db.Order("id DESC").Offset((page - 1) * pageSize).Limit(pageSize).Find(&logs).Error; err != nil {
+		return nil, 0, err
+	}
+	return logs, total, nil
+}
@@ -0,0 +1,100 @@
+package services
+
+import (
+	models "app/internal/app/model"
+	"errors"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+var (
+	ErrAdventureNotFound = errors.New("adventure task not found")
+	ErrInvalidAdventure  = errors.New("title cannot be empty and points must be non-negative")
+)
+
+// AdventureUpdate 修改冒险任务的字段，为 nil 的字段保持不变
+type AdventureUpdate struct {
+	Title       *string
+	Description *string
+	Points      *int
+	Active      *bool
+}
+
+// GetRandomAdventureTask 从冒险任务池中随机抽取一个启用的任务，任务池为空时返回 nil
+func (s *TaskService) GetRandomAdventureTask() (*models.AdventureTask, error) {
+	var task models.AdventureTask
+	err := s.db.Where("active = ?", true).Order("RAND()").First(&task).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &task, nil
+}
+
+// ListAdventureTasks 列出任务池中的全部冒险任务
+func (s *TaskService) ListAdventureTasks() ([]models.AdventureTask, error) {
+	var tasks []models.AdventureTask
+	err := s.db.Order("id").Find(&tasks).Error
+	return tasks, err
+}
+
+// CreateAdventureTask 向任务池中添加冒险任务，新任务默认启用
+func (s *TaskService) CreateAdventureTask(title, description string, points int) (*models.AdventureTask, error) {
+	title = strings.TrimSpace(title)
+	if title == "" || points < 0 {
+		return nil, ErrInvalidAdventure
+	}
+
+	task := models.AdventureTask{Title: title, Description: description, Points: points, Active: true}
+	if err := s.db.Create(&task).Error; err != nil {
+		return nil, err
+	}
+	return &task, nil
+}
+
+// UpdateAdventureTask 修改冒险任务，停用的任务不再被抽取
+func (s *TaskService) UpdateAdventureTask(id uint, update AdventureUpdate) (*models.AdventureTask, error) {
+	var task models.AdventureTask
+	if err := s.db.First(&task, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrAdventureNotFound
+		}
+		return nil, err
+	}
+
+	if update.Title != nil {
+		task.Title = strings.TrimSpace(*update.Title)
+	}
+	if update.Description != nil {
+		task.Description = *update.Description
+	}
+	if update.Points != nil {
+		task.Points = *update.Points
+	}
+	if update.Active != nil {
+		task.Active = *update.Active
+	}
+	if task.Title == "" || task.Points < 0 {
+		return nil, ErrInvalidAdventure
+	}
+
+	if err := s.db.Save(&task).Error; err != nil {
+		return nil, err
+	}
+	return &task, nil
+}
+
+// DeleteAdventureTask 从任务池中删除冒险任务
+func (s *TaskService) DeleteAdventureTask(id uint) error {
+	result := s.db.Delete(&models.AdventureTask{}, id)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrAdventureNotFound
+	}
+	return nil
+}
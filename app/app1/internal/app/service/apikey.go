@@ -0,0 +1,183 @@
+package services
+
+import (
+	models "app/internal/app/model"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// API key 的权限范围
+const (
+	ScopeReadOnly   = "read-only"   // 只能查询任务
+	ScopeTasksWrite = "tasks:write" // 可以创建和打卡任务，包含查询
+)
+
+const (
+	// APIKeyPrefix 区分 API key 和会话访问令牌
+	APIKeyPrefix = "gk_"
+	// DefaultAPIKeyDays 未指定有效期时的天数
+	DefaultAPIKeyDays = 90
+	// MaxAPIKeyDays 最长有效期
+	MaxAPIKeyDays = 365
+	// MaxAPIKeysPerUser 每个用户最多同时拥有的有效 key 数量
+	MaxAPIKeysPerUser = 20
+	// apiKeyTouchInterval 最近使用时间的更新间隔
+	apiKeyTouchInterval = time.Minute
+)
+
+var (
+	ErrInvalidAPIKeyName   = errors.New("API key name cannot be empty or longer than 100 characters")
+	ErrInvalidAPIKeyScope  = fmt.Errorf("scopes must be %q or %q", ScopeReadOnly, ScopeTasksWrite)
+	ErrInvalidAPIKeyExpiry = fmt.Errorf("expires_in_days must be between 1 and %d", MaxAPIKeyDays)
+	ErrTooManyAPIKeys      = fmt.Errorf("at most %d active API keys are allowed", MaxAPIKeysPerUser)
+	ErrAPIKeyNotFound      = errors.New("API key not found")
+)
+
+// IsAPIKeyError 判断是否为创建 API key 时的参数错误
+func IsAPIKeyError(err error) bool {
+	for _, target := range []error{ErrInvalidAPIKeyName, ErrInvalidAPIKeyScope, ErrInvalidAPIKeyExpiry, ErrTooManyAPIKeys} {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// APIKeyScopes 返回 key 的权限范围列表
+func APIKeyScopes(key *models.APIKey) []string {
+	if key.Scopes == "" {
+		return []string{}
+	}
+	return strings.Split(key.Scopes, ",")
+}
+
+// APIKeyHasScope 判断 key 是否拥有指定权限，tasks:write 包含只读权限
+func APIKeyHasScope(key *models.APIKey, scope string) bool {
+	for _, s := range APIKeyScopes(key) {
+		if s == scope || (s == ScopeTasksWrite && scope == ScopeReadOnly) {
+			return true
+		}
+	}
+	return false
+}
+
+// normalizeScopes 校验并去重权限范围
+func normalizeScopes(scopes []string) (string, error) {
+	seen := make(map[string]bool)
+	var result []string
+	for _, scope := range scopes {
+		scope = strings.TrimSpace(scope)
+		if scope != ScopeReadOnly && scope != ScopeTasksWrite {
+			return "", ErrInvalidAPIKeyScope
+		}
+		if !seen[scope] {
+			seen[scope] = true
+			result = append(result, scope)
+		}
+	}
+	if len(result) == 0 {
+		return "", ErrInvalidAPIKeyScope
+	}
+	return strings.Join(result, ","), nil
+}
+
+// CreateAPIKey 创建 API key，返回的明文 key 只在创建时出现一次
+func (s *AuthService) CreateAPIKey(userID uint, name string, scopes []string, expiresInDays int) (string, *models.APIKey, error) {
+	name = strings.TrimSpace(name)
+	if name == "" || len([]rune(name)) > 100 {
+		return "", nil, ErrInvalidAPIKeyName
+	}
+	scopeList, err := normalizeScopes(scopes)
+	if err != nil {
+		return "", nil, err
+	}
+	if expiresInDays == 0 {
+		expiresInDays = DefaultAPIKeyDays
+	}
+	if expiresInDays < 0 || expiresInDays > MaxAPIKeyDays {
+		return "", nil, ErrInvalidAPIKeyExpiry
+	}
+
+	var count int64
+	if err := s.db.Model(&models.APIKey{}).
+		Where("user_id = ? AND revoked_at IS NULL AND expires_at > ?", userID, time.Now()).
+		Count(&count).Error; err != nil {
+		return "", nil, err
+	}
+	if count >= MaxAPIKeysPerUser {
+		return "", nil, ErrTooManyAPIKeys
+	}
+
+	plain := APIKeyPrefix + generateToken()
+	key := models.APIKey{
+		UserID:    userID,
+		Name:      name,
+		Prefix:    plain[:len(APIKeyPrefix)+8],
+		KeyHash:   hashToken(plain),
+		Scopes:    scopeList,
+		ExpiresAt: time.Now().AddDate(0, 0, expiresInDays),
+	}
+	if err := s.db.Create(&key).Error; err != nil {
+		return "", nil, err
+	}
+	return plain, &key, nil
+}
+
+// ListAPIKeys 列出用户未撤销的 API key，包括已过期的
+func (s *AuthService) ListAPIKeys(userID uint) ([]models.APIKey, error) {
+	var keys []models.APIKey
+	err := s.db.Where("user_id = ? AND revoked_at IS NULL", userID).Order("id DESC").Find(&keys).Error
+	return keys, err
+}
+
+// RevokeAPIKey 撤销用户的某个 API key
+func (s *AuthService) RevokeAPIKey(userID, keyID uint) error {
+	now := time.Now()
+	result := s.db.Model(&models.APIKey{}).
+		Where("id = ? AND user_id = ? AND revoked_at IS NULL", keyID, userID).
+		Update("revoked_at", &now)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrAPIKeyNotFound
+	}
+	return nil
+}
+
+// AuthenticateAPIKey 校验 API key，返回所属用户和 key，并记录最近使用时间
+func (s *AuthService) AuthenticateAPIKey(plain string) (*models.User, *models.APIKey, error) {
+	var key models.APIKey
+	err := s.db.Where("key_hash = ? AND revoked_at IS NULL", hashToken(plain)).First(&key).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil, ErrInvalidToken
+		}
+		return nil, nil, err
+	}
+	now := time.Now()
+	if now.After(key.ExpiresAt) {
+		return nil, nil, ErrInvalidToken
+	}
+
+	var user models.User
+	if err := s.db.First(&user, key.UserID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil, ErrInvalidToken
+		}
+		return nil, nil, err
+	}
+	if err := checkUserActive(&user); err != nil {
+		return nil, nil, err
+	}
+
+	if key.LastUsedAt == nil || now.Sub(*key.LastUsedAt) > apiKeyTouchInterval {
+		key.LastUsedAt = &now
+		s.db.Model(&key).Update("last_used_at", &now)
+	}
+	return &user, &key, nil
+}
@@ -0,0 +1,298 @@
+package services
+
+import (
+	models "app/internal/app/model"
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// 打卡方式
+const (
+	CheckInCompleted = "completed" // 当天完成周期任务实例
+	CheckInMakeUp    = "make_up"   // 花积分补打卡，计入连续次数
+	CheckInFreeze    = "freeze"    // 使用保护卡，连续不中断但不计入次数
+)
+
+// 日历中发生日的状态，已打卡的日期使用打卡方式
+const (
+	CalendarMissed   = "missed"
+	CalendarPending  = "pending"
+	CalendarUpcoming = "upcoming"
+)
+
+// MaxCalendarDays 日历一次最多查询的天数
+const MaxCalendarDays = 366
+
+// StreakMilestone 连续打卡达到 Streak 次时奖励 Bonus 点习惯经验
+type StreakMilestone struct {
+	Streak int
+	Bonus  int
+}
+
+// StreakMilestones 连续打卡的里程碑，按次数从小到大排列
+var StreakMilestones = []StreakMilestone{
+	{Streak: 7, Bonus: 10},
+	{Streak: 30, Bonus: 50},
+	{Streak: 100, Bonus: 200},
+	{Streak: 365, Bonus: 1000},
+}
+
+var ErrInvalidDateRange = fmt.Errorf("from and to must be dates such as 2024-01-31, at most %d days apart", MaxCalendarDays)
+
+// HabitStreak 周期任务的连续打卡情况，连续次数按发生日计算，不是自然日
+type HabitStreak struct {
+	RecurringTaskID uint   `json:"recurring_task_id"`
+	Title           string `json:"title"`
+	CurrentStreak   int    `json:"current_streak"`   // 截至今天的连续打卡次数，今天尚未打卡不会中断
+	LongestStreak   int    `json:"longest_streak"`   // 历史最长连续打卡次数
+	TotalCheckIns   int    `json:"total_check_ins"`  // 打卡总次数，不含保护卡
+	CheckedInToday  bool   `json:"checked_in_today"` // 今天是否已打卡
+}
+
+// CalendarDay 日历中的一天，只包含发生日和有打卡记录的日期
+type CalendarDay struct {
+	Date   string `json:"date"`
+	Status string `json:"status"` // 打卡方式，或 missed、pending、upcoming
+}
+
+// HabitCalendar 周期任务在一段日期内的打卡日历
+type HabitCalendar struct {
+	HabitStreak
+	From string        `json:"from"`
+	To   string        `json:"to"`
+	Days []CalendarDay `json:"days"`
+}
+
+// habitSchedule 解析周期任务的规则和开始日期
+func habitSchedule(habit *models.RecurringTask) (*RRule, time.Time, error) {
+	rule, err := ParseRRule(habit.RRule)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	start, err := time.Parse(DateLayout, habit.StartDate)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	return rule, start, nil
+}
+
+// computeStreak 按发生日计算当前和最长连续打卡次数，今天尚未打卡不算中断，
+// 使用保护卡的发生日不中断也不计数
+func computeStreak(rule *RRule, start, today time.Time, checked map[string]string) (current, longest int) {
+	for _, day := range rule.Occurrences(start, start, today) {
+		if kind, ok := checked[day.Format(DateLayout)]; ok {
+			if kind == CheckInFreeze {
+				continue
+			}
+			current++
+			if current > longest {
+				longest = current
+			}
+		} else if !day.Equal(today) {
+			current = 0
+		}
+	}
+	return current, longest
+}
+
+// checkInsByHabit 读取用户的打卡记录，按周期任务分组，值为日期到打卡方式的映射
+func checkInsByHabit(db *gorm.DB, userID uint) (map[uint]map[string]string, error) {
+	var rows []models.CheckIn
+	if err := db.Where("user_id = ?", userID).Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	result := make(map[uint]map[string]string)
+	for _, row := range rows {
+		if result[row.RecurringTaskID] == nil {
+			result[row.RecurringTaskID] = make(map[string]string)
+		}
+		result[row.RecurringTaskID][row.Date] = row.Kind
+	}
+	return result, nil
+}
+
+// habitStreak 汇总一个周期任务的连续打卡情况
+func habitStreak(habit *models.RecurringTask, checked map[string]string, today time.Time) HabitStreak {
+	streak := HabitStreak{
+		RecurringTaskID: habit.ID,
+		Title:           habit.Title,
+	}
+	for _, kind := range checked {
+		if kind != CheckInFreeze {
+			streak.TotalCheckIns++
+		}
+	}
+	kind, ok := checked[today.Format(DateLayout)]
+	streak.CheckedInToday = ok && kind != CheckInFreeze
+	if rule, start, err := habitSchedule(habit); err == nil {
+		streak.CurrentStreak, streak.LongestStreak = computeStreak(rule, start, today, checked)
+	}
+	return streak
+}
+
+// recordCheckIn 周期任务实例在发生日当天完成时记录打卡，并在连续打卡达到里程碑时奖励习惯经验。
+// 过了发生日才完成的实例照常完成，但不算打卡，需要补打卡
+func recordCheckIn(tx *gorm.DB, task *models.Task, now time.Time) error {
+	if task.RecurrenceID == 0 {
+		return nil
+	}
+	var user models.User
+	if err := tx.First(&user, task.UserID).Error; err != nil {
+		return err
+	}
+	today := civilDate(now, UserLocation(&user))
+	if task.Occurrence != today.Format(DateLayout) {
+		return nil
+	}
+
+	var habit models.RecurringTask
+	if err := tx.Where("id = ? AND user_id = ?", task.RecurrenceID, task.UserID).First(&habit).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			// 周期任务已删除
+			return nil
+		}
+		return err
+	}
+	_, err := addCheckIn(tx, &habit, models.CheckIn{TaskID: task.ID, Date: task.Occurrence, Kind: CheckInCompleted}, today)
+	return err
+}
+
+// addCheckIn 写入一条打卡记录，同一天已有记录时返回 false 且不做任何事。
+// 写入后新达到的连续打卡里程碑发放奖励
+func addCheckIn(tx *gorm.DB, habit *models.RecurringTask, checkIn models.CheckIn, today time.Time) (bool, error) {
+	checkIn.UserID = habit.UserID
+	checkIn.RecurringTaskID = habit.ID
+	result := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&checkIn)
+	if result.Error != nil || result.RowsAffected == 0 {
+		return false, result.Error
+	}
+
+	rule, start, err := habitSchedule(habit)
+	if err != nil {
+		return true, nil
+	}
+	day, err := time.Parse(DateLayout, checkIn.Date)
+	if err != nil {
+		return true, nil
+	}
+	checkIns, err := checkInsByHabit(tx.Where("recurring_task_id = ?", habit.ID), habit.UserID)
+	if err != nil {
+		return true, err
+	}
+	for _, milestone := range newStreakMilestones(rule, start, day, today, checkIns[habit.ID]) {
+		if err := addReward(tx, habit.UserID, TrackHabit, milestone.Bonus, ExperienceSourceStreak,
+			fmt.Sprintf("%d check-in streak on recurring task %d", milestone.Streak, habit.ID)); err != nil {
+			return true, err
+		}
+	}
+	return true, nil
+}
+
+// newStreakMilestones 返回 day 的打卡写入后新达到的里程碑，checked 已包含 day。
+// 补上的一天接起前后两段时，两段各自已经达到的里程碑不算新达到
+func newStreakMilestones(rule *RRule, start, day, today time.Time, checked map[string]string) []StreakMilestone {
+	date := day.Format(DateLayout)
+	before := make(map[string]string, len(checked))
+	for d, kind := range checked {
+		if d != date {
+			before[d] = kind
+		}
+	}
+
+	// previous 为这一天之前的一段，current 为截至今天的一段，都是写入前的值
+	previous, _ := computeStreak(rule, start, day, before)
+	current, _ := computeStreak(rule, start, today, before)
+	if previous > current {
+		current = previous
+	}
+	after, _ := computeStreak(rule, start, today, checked)
+
+	var reached []StreakMilestone
+	for _, milestone := range StreakMilestones {
+		if milestone.Streak > current && milestone.Streak <= after {
+			reached = append(reached, milestone)
+		}
+	}
+	return reached
+}
+
+// ListStreaks 列出用户每个周期任务的连续打卡情况
+func (s *TaskService) ListStreaks(userID uint) ([]HabitStreak, error) {
+	var user models.User
+	if err := s.db.First(&user, userID).Error; err != nil {
+		return nil, err
+	}
+	habits, err := s.ListRecurringTasks(userID)
+	if err != nil {
+		return nil, err
+	}
+	checkIns, err := checkInsByHabit(s.db, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	today := civilDate(time.Now(), UserLocation(&user))
+	streaks := make([]HabitStreak, 0, len(habits))
+	for i := range habits {
+		streaks = append(streaks, habitStreak(&habits[i], checkIns[habits[i].ID], today))
+	}
+	return streaks, nil
+}
+
+// GetHabitCalendar 返回周期任务在 [from, to] 之间每个发生日的打卡状态
+func (s *TaskService) GetHabitCalendar(userID, habitID uint, from, to time.Time) (*HabitCalendar, error) {
+	if to.Before(from) || daysBetween(from, to) >= MaxCalendarDays {
+		return nil, ErrInvalidDateRange
+	}
+	var user models.User
+	if err := s.db.First(&user, userID).Error; err != nil {
+		return nil, err
+	}
+	habit, err := s.getRecurringTask(habitID, userID)
+	if err != nil {
+		return nil, err
+	}
+	rule, start, err := habitSchedule(habit)
+	if err != nil {
+		return nil, err
+	}
+	checkIns, err := checkInsByHabit(s.db.Where("recurring_task_id = ?", habitID), userID)
+	if err != nil {
+		return nil, err
+	}
+	checked := checkIns[habitID]
+
+	today := civilDate(time.Now(), UserLocation(&user))
+	calendar := &HabitCalendar{
+		HabitStreak: habitStreak(habit, checked, today),
+		From:        from.Format(DateLayout),
+		To:          to.Format(DateLayout),
+		Days:        []CalendarDay{},
+	}
+	scheduled := make(map[string]bool)
+	for _, day := range rule.Occurrences(start, from, to) {
+		scheduled[day.Format(DateLayout)] = true
+	}
+	for day := from; !day.After(to); day = day.AddDate(0, 0, 1) {
+		date := day.Format(DateLayout)
+		status, ok := checked[date]
+		switch {
+		case ok:
+			// 已打卡的日期使用打卡方式作为状态
+		case !scheduled[date]:
+			continue
+		case day.Before(today):
+			status = CalendarMissed
+		case day.Equal(today):
+			status = CalendarPending
+		default:
+			status = CalendarUpcoming
+		}
+		calendar.Days = append(calendar.Days, CalendarDay{Date: date, Status: status})
+	}
+	return calendar, nil
+}
@@ -0,0 +1,154 @@
+package services
+
+import "testing"
+
+// checkInDays 生成 from 到 to 每天的打卡记录
+func checkInDays(t *testing.T, checked map[string]string, from, to, kind string) map[string]string {
+	t.Helper()
+	if checked == nil {
+		checked = make(map[string]string)
+	}
+	for day := date(t, from); !day.After(date(t, to)); day = day.AddDate(0, 0, 1) {
+		checked[day.Format(DateLayout)] = kind
+	}
+	return checked
+}
+
+func TestComputeStreak(t *testing.T) {
+	tests := []struct {
+		name        string
+		rule        string
+		today       string
+		checked     func(t *testing.T) map[string]string
+		wantCurrent int
+		wantLongest int
+	}{
+		{"no check-ins", "FREQ=DAILY", "2024-01-05",
+			func(t *testing.T) map[string]string { return nil }, 0, 0},
+		{"checked in every day", "FREQ=DAILY", "2024-01-05",
+			func(t *testing.T) map[string]string {
+				return checkInDays(t, nil, "2024-01-01", "2024-01-05", CheckInCompleted)
+			}, 5, 5},
+		{"today not checked in yet", "FREQ=DAILY", "2024-01-05",
+			func(t *testing.T) map[string]string {
+				return checkInDays(t, nil, "2024-01-01", "2024-01-04", CheckInCompleted)
+			}, 4, 4},
+		{"missed yesterday", "FREQ=DAILY", "2024-01-05",
+			func(t *testing.T) map[string]string {
+				return checkInDays(t, nil, "2024-01-01", "2024-01-03", CheckInCompleted)
+			}, 0, 3},
+		{"restarted after a gap", "FREQ=DAILY", "2024-01-05",
+			func(t *testing.T) map[string]string {
+				checked := checkInDays(t, nil, "2024-01-01", "2024-01-03", CheckInCompleted)
+				return checkInDays(t, checked, "2024-01-05", "2024-01-05", CheckInCompleted)
+			}, 1, 3},
+		{"freeze bridges without counting", "FREQ=DAILY", "2024-01-04",
+			func(t *testing.T) map[string]string {
+				checked := checkInDays(t, nil, "2024-01-01", "2024-01-02", CheckInCompleted)
+				checked = checkInDays(t, checked, "2024-01-03", "2024-01-03", CheckInFreeze)
+				return checkInDays(t, checked, "2024-01-04", "2024-01-04", CheckInCompleted)
+			}, 3, 3},
+		{"make-up counts", "FREQ=DAILY", "2024-01-03",
+			func(t *testing.T) map[string]string {
+				checked := checkInDays(t, nil, "2024-01-01", "2024-01-03", CheckInCompleted)
+				return checkInDays(t, checked, "2024-01-02", "2024-01-02", CheckInMakeUp)
+			}, 3, 3},
+		{"only occurrence days count", "FREQ=WEEKLY;BYDAY=MO", "2024-01-10",
+			func(t *testing.T) map[string]string {
+				checked := checkInDays(t, nil, "2024-01-01", "2024-01-01", CheckInCompleted)
+				return checkInDays(t, checked, "2024-01-08", "2024-01-08", CheckInCompleted)
+			}, 2, 2},
+		{"missed weekly occurrence", "FREQ=WEEKLY;BYDAY=MO", "2024-01-16",
+			func(t *testing.T) map[string]string {
+				return checkInDays(t, nil, "2024-01-01", "2024-01-01", CheckInCompleted)
+			}, 0, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rule, err := ParseRRule(tt.rule)
+			if err != nil {
+				t.Fatalf("ParseRRule(%q): %v", tt.rule, err)
+			}
+			current, longest := computeStreak(rule, date(t, "2024-01-01"), date(t, tt.today), tt.checked(t))
+			if current != tt.wantCurrent || longest != tt.wantLongest {
+				t.Errorf("computeStreak = (%d, %d), want (%d, %d)", current, longest, tt.wantCurrent, tt.wantLongest)
+			}
+		})
+	}
+}
+
+func TestNewStreakMilestones(t *testing.T) {
+	tests := []struct {
+		name    string
+		day     string
+		today   string
+		checked func(t *testing.T) map[string]string
+		want    []int
+	}{
+		{"reaches 7", "2024-01-07", "2024-01-07",
+			func(t *testing.T) map[string]string {
+				return checkInDays(t, nil, "2024-01-01", "2024-01-07", CheckInCompleted)
+			}, []int{7}},
+		{"below first milestone", "2024-01-06", "2024-01-06",
+			func(t *testing.T) map[string]string {
+				return checkInDays(t, nil, "2024-01-01", "2024-01-06", CheckInCompleted)
+			}, nil},
+		{"past milestone is not paid again", "2024-01-08", "2024-01-08",
+			func(t *testing.T) map[string]string {
+				return checkInDays(t, nil, "2024-01-01", "2024-01-08", CheckInCompleted)
+			}, nil},
+		{"reaches 30", "2024-01-30", "2024-01-30",
+			func(t *testing.T) map[string]string {
+				return checkInDays(t, nil, "2024-01-01", "2024-01-30", CheckInCompleted)
+			}, []int{30}},
+		{"freeze day does not count towards 7", "2024-01-08", "2024-01-08",
+			func(t *testing.T) map[string]string {
+				checked := checkInDays(t, nil, "2024-01-01", "2024-01-08", CheckInCompleted)
+				return checkInDays(t, checked, "2024-01-07", "2024-01-07", CheckInFreeze)
+			}, []int{7}},
+		{"make-up joins two short runs", "2024-01-04", "2024-01-08",
+			func(t *testing.T) map[string]string {
+				checked := checkInDays(t, nil, "2024-01-01", "2024-01-08", CheckInCompleted)
+				return checkInDays(t, checked, "2024-01-04", "2024-01-04", CheckInMakeUp)
+			}, []int{7}},
+		{"make-up joins a run that already reached 7", "2024-01-07", "2024-01-14",
+			func(t *testing.T) map[string]string {
+				checked := checkInDays(t, nil, "2024-01-01", "2024-01-14", CheckInCompleted)
+				return checkInDays(t, checked, "2024-01-07", "2024-01-07", CheckInMakeUp)
+			}, nil},
+		{"make-up after an earlier run reached 7", "2024-01-09", "2024-01-11",
+			func(t *testing.T) map[string]string {
+				checked := checkInDays(t, nil, "2024-01-01", "2024-01-11", CheckInCompleted)
+				return checkInDays(t, checked, "2024-01-09", "2024-01-09", CheckInMakeUp)
+			}, nil},
+	}
+	rule, err := ParseRRule("FREQ=DAILY")
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := date(t, "2024-01-01")
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []int
+			for _, milestone := range newStreakMilestones(rule, start, date(t, tt.day), date(t, tt.today), tt.checked(t)) {
+				got = append(got, milestone.Streak)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("newStreakMilestones = %v, want %v", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Fatalf("newStreakMilestones = %v, want %v", got, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestStreakMilestonesSorted(t *testing.T) {
+	for i := 1; i < len(StreakMilestones); i++ {
+		if StreakMilestones[i].Streak <= StreakMilestones[i-1].Streak {
+			t.Fatalf("StreakMilestones not sorted at %d", i)
+		}
+	}
+}
@@ -0,0 +1,93 @@
+package services
+
+import (
+	models "app/internal/app/model"
+	"log"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	// WeeklyDigestInterval 周报统计的时间范围
+	WeeklyDigestInterval = 7 * 24 * time.Hour
+	// WeeklyDigestCheckInterval 检查是否有用户到了周报发送时间的间隔
+	WeeklyDigestCheckInterval = time.Hour
+	// WeeklyDigestWeekday 和 WeeklyDigestHour 按用户时区，每周一早上 8 点发送周报
+	WeeklyDigestWeekday = time.Monday
+	WeeklyDigestHour    = 8
+	// digestBatchSize 每次从数据库读取的用户数
+	digestBatchSize = 500
+)
+
+// weeklyDigestSlot 返回 now 之前最近一次的周报发送时间，按 loc 时区计算
+func weeklyDigestSlot(now time.Time, loc *time.Location) time.Time {
+	local := now.In(loc)
+	slot := time.Date(local.Year(), local.Month(), local.Day(), WeeklyDigestHour, 0, 0, 0, loc)
+	slot = slot.AddDate(0, 0, -((int(local.Weekday()) - int(WeeklyDigestWeekday) + 7) % 7))
+	if slot.After(now) {
+		slot = slot.AddDate(0, 0, -7)
+	}
+	return slot
+}
+
+// SendWeeklyDigests 给到了周报发送时间、本周还没有收到周报的用户发送上一周的任务周报，
+// 由后台任务定期调用。封禁中、已注销或申请注销的用户不发送。每个用户先记录发送时间再发送，
+// 多个实例同时运行或重启后不会重复发送；单个用户发送失败只记录日志
+func (s *AuthService) SendWeeklyDigests(now time.Time) error {
+	var users []models.User
+	return s.db.Where("deleted_at IS NULL AND deletion_scheduled_at IS NULL").
+		Where("banned_at IS NULL OR (banned_until IS NOT NULL AND banned_until <= ?)", now).
+		FindInBatches(&users, digestBatchSize, func(tx *gorm.DB, batch int) error {
+			for i := range users {
+				if err := s.sendWeeklyDigest(&users[i], now); err != nil {
+					log.Printf("send weekly digest to user %d: %v", users[i].ID, err)
+				}
+			}
+			return nil
+		}).Error
+}
+
+// sendWeeklyDigest 检查用户本周的周报是否已发送，没有则记录发送时间并发送
+func (s *AuthService) sendWeeklyDigest(user *models.User, now time.Time) error {
+	slot := weeklyDigestSlot(now, UserLocation(user))
+	if user.DigestSentAt != nil && !user.DigestSentAt.Before(slot) {
+		return nil
+	}
+	// 发送时间之后注册的用户从下一周开始发送
+	if user.CreatedAt.After(slot) {
+		return nil
+	}
+
+	result := s.db.Model(&models.User{}).
+		Where("id = ? AND (digest_sent_at IS NULL OR digest_sent_at < ?)", user.ID, slot).
+		Update("digest_sent_at", now)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil
+	}
+
+	from := slot.Add(-WeeklyDigestInterval)
+	var stats struct {
+		Count  int
+		Points int
+	}
+	err := s.db.Model(&models.Task{}).
+		Select("COUNT(*) AS count, COALESCE(SUM(points), 0) AS points").
+		Where("user_id = ? AND completed = ? AND completed_at >= ? AND completed_at < ?", user.ID, true, from, slot).
+		Scan(&stats).Error
+	if err != nil {
+		return err
+	}
+
+	return s.sendMail(MailWeeklyDigest, user.Locale, user.Email, map[string]interface{}{
+		"Username":       user.Username,
+		"Level":          user.Level,
+		"CompletedTasks": stats.Count,
+		"Points":         stats.Points,
+		"From":           from,
+		"To":             slot,
+	})
+}
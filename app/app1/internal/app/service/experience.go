@@ -0,0 +1,88 @@
+package services
+
+import (
+	models "app/internal/app/model"
+	"errors"
+
+	"gorm.io/gorm"
+)
+
+// 经验类型，除总经验外对应升级所需的四项经验；可用积分也按同样的方式记录变动
+const (
+	TrackExperience      = "experience"
+	TrackSelfImprovement = "self_improvement"
+	TrackWork            = "work"
+	TrackHabit           = "habit"
+	TrackTodo            = "todo"
+	TrackPoints          = "points"
+)
+
+// 经验变动来源
+const (
+	ExperienceSourceAdmin        = "admin"
+	ExperienceSourceTask         = "task"
+	ExperienceSourceStreak       = "streak"
+	ExperienceSourceStreakFreeze = "streak_freeze"
+	ExperienceSourceMakeUp       = "make_up"
+	ExperienceSourceConvert      = "convert"
+)
+
+// trackColumns 经验类型对应的用户表字段
+var trackColumns = map[string]string{
+	TrackExperience:      "experience",
+	TrackSelfImprovement: "self_improvement_exp",
+	TrackWork:            "work_exp",
+	TrackHabit:           "habit_exp",
+	TrackTodo:            "todo_exp",
+	TrackPoints:          "points",
+}
+
+var (
+	ErrInvalidTrack       = errors.New("track must be experience, self_improvement, work, habit, todo or points")
+	ErrNegativeExperience = errors.New("experience cannot become negative")
+)
+
+// trackValue 返回用户某项经验的当前值
+func trackValue(user *models.User, track string) int {
+	switch track {
+	case TrackSelfImprovement:
+		return user.SelfImprovementExp
+	case TrackWork:
+		return user.WorkExp
+	case TrackHabit:
+		return user.HabitExp
+	case TrackTodo:
+		return user.TodoExp
+	case TrackPoints:
+		return user.Points
+	default:
+		return user.Experience
+	}
+}
+
+// addExperience 在事务中增减用户的某项经验并记录变动
+func addExperience(tx *gorm.DB, userID uint, track string, delta int, source, reason string) error {
+	column, ok := trackColumns[track]
+	if !ok {
+		return ErrInvalidTrack
+	}
+	if err := tx.Model(&models.User{}).Where("id = ?", userID).
+		Update(column, gorm.Expr(column+" + ?", delta)).Error; err != nil {
+		return err
+	}
+	return tx.Create(&models.ExperienceLog{
+		UserID: userID,
+		Track:  track,
+		Delta:  delta,
+		Source: source,
+		Reason: reason,
+	}).Error
+}
+
+// addReward 发放任务或打卡奖励：增加对应的经验，同时获得同样多的可用积分
+func addReward(tx *gorm.DB, userID uint, track string, amount int, source, reason string) error {
+	if err := addExperience(tx, userID, track, amount, source, reason); err != nil {
+		return err
+	}
+	return addExperience(tx, userID, TrackPoints, amount, source, reason)
+}
@@ -0,0 +1,251 @@
+package services
+
+import (
+	models "app/internal/app/model"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// OIDCStateTTL 跳转到 provider 后完成登录的时限
+const OIDCStateTTL = 10 * time.Minute
+
+var (
+	ErrInvalidOIDCState      = errors.New("login session expired or invalid, please try again")
+	ErrIdentityLinked        = errors.New("this identity is already linked to another account")
+	ErrIdentityNotFound      = errors.New("identity not found")
+	ErrLastLoginMethod       = errors.New("set a password or link another identity before removing the last login method")
+	ErrIdentityAlreadyLinked = errors.New("an identity from this provider is already linked to your account")
+)
+
+// IsOIDCError 判断是否为第三方登录流程中客户端可以处理的错误
+func IsOIDCError(err error) bool {
+	for _, target := range []error{ErrInvalidOIDCState, ErrIdentityLinked, ErrOIDCEmailUnverified,
+		ErrInvalidIDToken, ErrLastLoginMethod, ErrIdentityAlreadyLinked} {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// OIDCProviders 返回配置的第三方登录 provider，顺序与配置一致
+func (s *AuthService) OIDCProviders() []*OIDCProvider {
+	return s.oidcProviders
+}
+
+// oidcProvider 按名称查找 provider
+func (s *AuthService) oidcProvider(name string) (*OIDCProvider, error) {
+	for _, p := range s.oidcProviders {
+		if p.Name() == name {
+			return p, nil
+		}
+	}
+	return nil, ErrOIDCProviderNotFound
+}
+
+// StartOIDCLogin 保存 state、nonce 和 PKCE 参数，返回 provider 的授权地址；
+// userID 不为 0 时表示已登录用户绑定新身份
+func (s *AuthService) StartOIDCLogin(providerName string, userID uint) (string, error) {
+	provider, err := s.oidcProvider(providerName)
+	if err != nil {
+		return "", err
+	}
+
+	state, nonce, verifier := generateToken(), generateToken(), generateToken()
+	authURL, err := provider.AuthCodeURL(state, nonce, verifier)
+	if err != nil {
+		return "", err
+	}
+
+	// 顺便清理过期的 state
+	s.db.Unscoped().Where("expires_at < ?", time.Now()).Delete(&models.OIDCLoginState{})
+	if err := s.db.Create(&models.OIDCLoginState{
+		StateHash:    hashToken(state),
+		Provider:     providerName,
+		Nonce:        nonce,
+		CodeVerifier: verifier,
+		UserID:       userID,
+		ExpiresAt:    time.Now().Add(OIDCStateTTL),
+	}).Error; err != nil {
+		return "", err
+	}
+	return authURL, nil
+}
+
+// FinishOIDCLogin 校验 state 并用授权码换取 ID token，返回对应的用户。
+// 已绑定的身份直接登录；绑定流程中把身份绑定到发起绑定的用户；
+// 否则按已验证的邮箱关联已有用户，没有则创建新用户。linking 表示本次是绑定流程
+func (s *AuthService) FinishOIDCLogin(providerName, state, code string) (user *models.User, linking bool, err error) {
+	provider, err := s.oidcProvider(providerName)
+	if err != nil {
+		return nil, false, err
+	}
+
+	var loginState models.OIDCLoginState
+	if err := s.db.Where("state_hash = ? AND provider = ?", hashToken(state), providerName).
+		First(&loginState).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, false, ErrInvalidOIDCState
+		}
+		return nil, false, err
+	}
+	// state 只能使用一次
+	if err := s.db.Unscoped().Delete(&loginState).Error; err != nil {
+		return nil, false, err
+	}
+	if time.Now().After(loginState.ExpiresAt) {
+		return nil, false, ErrInvalidOIDCState
+	}
+	linking = loginState.UserID != 0
+
+	claims, err := provider.Exchange(code, loginState.CodeVerifier, loginState.Nonce)
+	if err != nil {
+		return nil, linking, err
+	}
+	email := strings.TrimSpace(claims.Email)
+
+	var userID uint
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		var identity models.OIDCIdentity
+		err := tx.Where("provider = ? AND subject = ?", providerName, claims.Subject).First(&identity).Error
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+
+		now := time.Now()
+		if err == nil {
+			if linking && identity.UserID != loginState.UserID {
+				return ErrIdentityLinked
+			}
+			userID = identity.UserID
+			return tx.Model(&identity).Updates(map[string]interface{}{"last_login_at": &now, "email": email}).Error
+		}
+
+		switch {
+		case linking:
+			var count int64
+			if err := tx.Model(&models.OIDCIdentity{}).
+				Where("user_id = ? AND provider = ?", loginState.UserID, providerName).Count(&count).Error; err != nil {
+				return err
+			}
+			if count > 0 {
+				return ErrIdentityAlreadyLinked
+			}
+			userID = loginState.UserID
+		case email == "" || !claims.EmailVerified:
+			return ErrOIDCEmailUnverified
+		default:
+			var existing models.User
+			err := tx.Where("email = ?", email).First(&existing).Error
+			switch {
+			case err == nil:
+				userID = existing.ID
+			case errors.Is(err, gorm.ErrRecordNotFound):
+				created, err := createOIDCUser(tx, email, claims)
+				if err != nil {
+					return err
+				}
+				userID = created.ID
+			default:
+				return err
+			}
+		}
+
+		return tx.Create(&models.OIDCIdentity{
+			UserID:      userID,
+			Provider:    providerName,
+			Subject:     claims.Subject,
+			Email:       email,
+			LastLoginAt: &now,
+		}).Error
+	})
+	if err != nil {
+		return nil, linking, err
+	}
+
+	user, err = s.GetUser(userID)
+	if err != nil {
+		return nil, linking, err
+	}
+	if err := checkUserActive(user); err != nil {
+		return nil, linking, err
+	}
+	return user, linking, nil
+}
+
+// createOIDCUser 为第一次通过第三方登录的用户创建账号，没有密码
+func createOIDCUser(tx *gorm.DB, email string, claims *OIDCClaims) (*models.User, error) {
+	base := strings.TrimSpace(claims.PreferredUsername)
+	if base == "" {
+		base = strings.TrimSpace(claims.Name)
+	}
+	if base == "" {
+		base = strings.SplitN(email, "@", 2)[0]
+	}
+
+	// 用户名已被占用时追加数字
+	username := base
+	for i := 2; ; i++ {
+		var count int64
+		if err := tx.Model(&models.User{}).Where("username = ?", username).Count(&count).Error; err != nil {
+			return nil, err
+		}
+		if count == 0 {
+			break
+		}
+		if i > 100 {
+			username = base + "-" + generateRandomString(6)
+			break
+		}
+		username = fmt.Sprintf("%s%d", base, i)
+	}
+
+	user := models.User{
+		Email:    email,
+		Username: username,
+		Level:    1,
+		Locale:   DefaultLocale,
+	}
+	if err := tx.Create(&user).Error; err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// ListIdentities 列出用户绑定的第三方身份
+func (s *AuthService) ListIdentities(userID uint) ([]models.OIDCIdentity, error) {
+	var identities []models.OIDCIdentity
+	err := s.db.Where("user_id = ?", userID).Order("id").Find(&identities).Error
+	return identities, err
+}
+
+// UnlinkIdentity 解除绑定，用户必须至少保留密码或一个第三方身份用于登录
+func (s *AuthService) UnlinkIdentity(userID, identityID uint) error {
+	user, err := s.GetUser(userID)
+	if err != nil {
+		return err
+	}
+
+	var identity models.OIDCIdentity
+	if err := s.db.Where("id = ? AND user_id = ?", identityID, userID).First(&identity).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrIdentityNotFound
+		}
+		return err
+	}
+
+	if user.Password == "" {
+		var count int64
+		if err := s.db.Model(&models.OIDCIdentity{}).Where("user_id = ?", userID).Count(&count).Error; err != nil {
+			return err
+		}
+		if count <= 1 {
+			return ErrLastLoginMethod
+		}
+	}
+	return s.db.Unscoped().Delete(&identity).Error
+}
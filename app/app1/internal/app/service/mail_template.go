@@ -0,0 +1,140 @@
+package services
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	htmltemplate "html/template"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	texttemplate "text/template"
+)
+
+// 邮件模板名称
+const (
+	MailVerification  = "verification"
+	MailPasswordReset = "password_reset"
+	MailLevelUp       = "level_up"
+	MailWeeklyDigest  = "weekly_digest"
+	MailTaskReminder  = NotificationTaskReminder
+	MailTaskOverdue   = NotificationTaskOverdue
+)
+
+const (
+	LocaleZhCN = "zh-CN"
+	LocaleEn   = "en"
+	// DefaultLocale 用户未设置语言时使用中文
+	DefaultLocale = LocaleZhCN
+)
+
+//go:embed templates/mail/*.tmpl
+var defaultMailTemplates embed.FS
+
+// mailTemplate 同一个模板文件分别按纯文本和 HTML 解析，
+// 文件中需定义 subject、text、html 三个块
+type mailTemplate struct {
+	text *texttemplate.Template
+	html *htmltemplate.Template
+}
+
+// MailTemplates 事务邮件模板，按 “名称.语言.tmpl” 索引
+type MailTemplates struct {
+	templates map[string]*mailTemplate
+}
+
+// LoadMailTemplates 加载内置模板，dir 不为空且存在时，目录中的同名文件覆盖内置模板
+func LoadMailTemplates(dir string) (*MailTemplates, error) {
+	t := &MailTemplates{templates: make(map[string]*mailTemplate)}
+
+	sub, err := fs.Sub(defaultMailTemplates, "templates/mail")
+	if err != nil {
+		return nil, err
+	}
+	if err := t.load(sub); err != nil {
+		return nil, err
+	}
+
+	if dir != "" {
+		if info, err := os.Stat(dir); err == nil && info.IsDir() {
+			if err := t.load(os.DirFS(dir)); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return t, nil
+}
+
+func (t *MailTemplates) load(fsys fs.FS) error {
+	files, err := fs.Glob(fsys, "*.tmpl")
+	if err != nil {
+		return err
+	}
+	for _, file := range files {
+		content, err := fs.ReadFile(fsys, file)
+		if err != nil {
+			return err
+		}
+		text, err := texttemplate.New(file).Parse(string(content))
+		if err != nil {
+			return fmt.Errorf("parse mail template %s: %w", file, err)
+		}
+		html, err := htmltemplate.New(file).Parse(string(content))
+		if err != nil {
+			return fmt.Errorf("parse mail template %s: %w", file, err)
+		}
+		t.templates[strings.TrimSuffix(filepath.Base(file), ".tmpl")] = &mailTemplate{text: text, html: html}
+	}
+	return nil
+}
+
+// ParseLocale 识别 zh、zh_CN、en-US 等写法，返回对应的支持语言
+func ParseLocale(locale string) (string, bool) {
+	locale = strings.ToLower(strings.ReplaceAll(locale, "_", "-"))
+	switch {
+	case strings.HasPrefix(locale, "zh"):
+		return LocaleZhCN, true
+	case strings.HasPrefix(locale, "en"):
+		return LocaleEn, true
+	default:
+		return DefaultLocale, false
+	}
+}
+
+// NormalizeLocale 同 ParseLocale，无法识别时返回默认语言
+func NormalizeLocale(locale string) string {
+	normalized, _ := ParseLocale(locale)
+	return normalized
+}
+
+// Render 渲染邮件，找不到对应语言的模板时依次退回默认语言和英文
+func (t *MailTemplates) Render(name, locale, to string, data interface{}) (MailMessage, error) {
+	var tmpl *mailTemplate
+	for _, l := range []string{NormalizeLocale(locale), DefaultLocale, LocaleEn} {
+		if tmpl = t.templates[name+"."+l]; tmpl != nil {
+			break
+		}
+	}
+	if tmpl == nil {
+		return MailMessage{}, fmt.Errorf("mail template %q not found", name)
+	}
+
+	var subject, text, html bytes.Buffer
+	if err := tmpl.text.ExecuteTemplate(&subject, "subject", data); err != nil {
+		return MailMessage{}, err
+	}
+	if err := tmpl.text.ExecuteTemplate(&text, "text", data); err != nil {
+		return MailMessage{}, err
+	}
+	if err := tmpl.html.ExecuteTemplate(&html, "html", data); err != nil {
+		return MailMessage{}, err
+	}
+
+	return MailMessage{
+		To:       to,
+		Subject:  strings.TrimSpace(subject.String()),
+		Body:     strings.TrimSpace(text.String()),
+		HTMLBody: strings.TrimSpace(html.String()),
+	}, nil
+}
@@ -0,0 +1,109 @@
+package services
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/go-gomail/gomail"
+)
+
+// MailMessage 一封待发送的邮件
+type MailMessage struct {
+	To       string
+	Subject  string
+	Body     string // 纯文本正文，不支持 HTML 的客户端显示这一部分
+	HTMLBody string // HTML 正文，可以为空
+}
+
+// Mailer 邮件发送接口，按配置选择 SMTP、写入 .eml 文件或保存在内存中
+type Mailer interface {
+	Send(msg MailMessage) error
+}
+
+// newGomailMessage 把 MailMessage 转换为 gomail 消息，SMTP 和文件两种实现共用
+func newGomailMessage(from string, msg MailMessage) *gomail.Message {
+	m := gomail.NewMessage()
+	m.SetHeader("From", from)
+	m.SetHeader("To", msg.To)
+	m.SetHeader("Subject", msg.Subject)
+	m.SetDateHeader("Date", time.Now())
+	m.SetBody("text/plain", msg.Body)
+	if msg.HTMLBody != "" {
+		m.AddAlternative("text/html", msg.HTMLBody)
+	}
+	return m
+}
+
+// SMTPMailer 通过 SMTP 服务器发送邮件
+type SMTPMailer struct {
+	dialer *gomail.Dialer
+	from   string
+}
+
+// NewSMTPMailer 创建 SMTP 邮件发送器，from 为空时使用 SMTP 用户名
+func NewSMTPMailer(host string, port int, username, password, from string) *SMTPMailer {
+	if from == "" {
+		from = username
+	}
+	return &SMTPMailer{dialer: gomail.NewDialer(host, port, username, password), from: from}
+}
+
+func (m *SMTPMailer) Send(msg MailMessage) error {
+	return m.dialer.DialAndSend(newGomailMessage(m.from, msg))
+}
+
+// FileMailer 把邮件写成 .eml 文件放到目录中，本地开发时用邮件客户端直接打开查看
+type FileMailer struct {
+	dir  string
+	from string
+}
+
+// NewFileMailer 创建文件邮件发送器，目录不存在时自动创建
+func NewFileMailer(dir, from string) (*FileMailer, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	return &FileMailer{dir: dir, from: from}, nil
+}
+
+func (m *FileMailer) Send(msg MailMessage) error {
+	name := fmt.Sprintf("%s-%s.eml", time.Now().Format("20060102-150405.000000000"), generateRandomString(6))
+	f, err := os.Create(filepath.Join(m.dir, name))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if _, err := newGomailMessage(m.from, msg).WriteTo(f); err != nil {
+		return err
+	}
+	return f.Close()
+}
+
+// MemoryMailer 把邮件保存在内存中，供测试读取
+type MemoryMailer struct {
+	mu       sync.Mutex
+	messages []MailMessage
+}
+
+// NewMemoryMailer 创建内存邮件发送器
+func NewMemoryMailer() *MemoryMailer {
+	return &MemoryMailer{}
+}
+
+func (m *MemoryMailer) Send(msg MailMessage) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.messages = append(m.messages, msg)
+	return nil
+}
+
+// Messages 返回目前发送过的全部邮件
+func (m *MemoryMailer) Messages() []MailMessage {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]MailMessage(nil), m.messages...)
+}
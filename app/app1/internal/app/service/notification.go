@@ -0,0 +1,72 @@
+package services
+
+import (
+	models "app/internal/app/model"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	// DefaultNotificationPageSize 通知列表每页默认条数
+	DefaultNotificationPageSize = 20
+	// MaxNotificationPageSize 通知列表每页最多条数
+	MaxNotificationPageSize = 100
+)
+
+var ErrNotificationNotFound = errors.New("notification not found")
+
+// ListNotifications 按时间倒序列出用户的通知，同时返回总数和未读数
+func (s *AuthService) ListNotifications(userID uint, unreadOnly bool, page, pageSize int) ([]models.Notification, int64, int64, error) {
+	if page < 1 {
+		page = 1
+	}
+	if pageSize < 1 {
+		pageSize = DefaultNotificationPageSize
+	}
+	if pageSize > MaxNotificationPageSize {
+		pageSize = MaxNotificationPageSize
+	}
+
+	var unread int64
+	if err := s.db.Model(&models.Notification{}).Where("user_id = ? AND read_at IS NULL", userID).
+		Count(&unread).Error; err != nil {
+		return nil, 0, 0, err
+	}
+
+	db := s.db.Model(&models.Notification{}).Where("user_id = ?", userID)
+	if unreadOnly {
+		db = db.Where("read_at IS NULL")
+	}
+	var total int64
+	if err := db.Count(&total).Error; err != nil {
+		return nil, 0, 0, err
+	}
+	notifications := []models.Notification{}
+	if err := db.Order("id DESC").Offset((page - 1) * pageSize).Limit(pageSize).Find(&notifications).Error; err != nil {
+		return nil, 0, 0, err
+	}
+	return notifications, total, unread, nil
+}
+
+// MarkNotificationRead 把用户的一条通知标记为已读
+func (s *AuthService) MarkNotificationRead(userID, notificationID uint) error {
+	var notification models.Notification
+	if err := s.db.Where("id = ? AND user_id = ?", notificationID, userID).First(&notification).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrNotificationNotFound
+		}
+		return err
+	}
+	if notification.ReadAt != nil {
+		return nil
+	}
+	return s.db.Model(&notification).Update("read_at", time.Now()).Error
+}
+
+// MarkAllNotificationsRead 把用户的全部未读通知标记为已读
+func (s *AuthService) MarkAllNotificationsRead(userID uint) error {
+	return s.db.Model(&models.Notification{}).Where("user_id = ? AND read_at IS NULL", userID).
+		Update("read_at", time.Now()).Error
+}
@@ -1,12 +1,99 @@
 package services
 
 import (
+	"crypto/rand"
 	"crypto/sha256"
+	"crypto/subtle"
 	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/crypto/argon2"
+)
+
+// argon2id 参数，调整后旧参数生成的哈希会在下次登录时自动重新计算
+const (
+	argon2Time    uint32 = 1
+	argon2Memory  uint32 = 64 * 1024
+	argon2Threads uint8  = 4
+	argon2KeyLen  uint32 = 32
+	argon2SaltLen        = 16
 )
 
-func hashPassword(password, salt string) string {
+// MinPasswordLength 密码最短长度
+const MinPasswordLength = 8
+
+// ErrWeakPassword 密码不满足长度要求
+var ErrWeakPassword = errors.New("password must be at least 8 characters")
+
+// validatePassword 校验新密码是否满足要求
+func validatePassword(password string) error {
+	if utf8.RuneCountInString(password) < MinPasswordLength {
+		return ErrWeakPassword
+	}
+	return nil
+}
+
+// hashPassword 使用 argon2id 计算密码哈希，结果为 PHC 格式：
+// $argon2id$v=19$m=65536,t=1,p=4$<salt>$<hash>
+func hashPassword(password string) (string, error) {
+	salt := make([]byte, argon2SaltLen)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+	key := argon2.IDKey([]byte(password), salt, argon2Time, argon2Memory, argon2Threads, argon2KeyLen)
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2.Version, argon2Memory, argon2Time, argon2Threads,
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(key)), nil
+}
+
+// verifyPassword 校验密码，needsRehash 表示哈希是旧格式或参数已过时，应当重新计算
+func verifyPassword(password, encoded string) (ok bool, needsRehash bool) {
+	if !strings.HasPrefix(encoded, "$argon2id$") {
+		return verifyLegacyPassword(password, encoded), true
+	}
+
+	var version int
+	var memory, iterations uint32
+	var threads uint8
+	parts := strings.Split(encoded, "$")
+	if len(parts) != 6 {
+		return false, false
+	}
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return false, false
+	}
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &memory, &iterations, &threads); err != nil || iterations == 0 || threads == 0 {
+		return false, false
+	}
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return false, false
+	}
+	want, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil || len(want) == 0 {
+		return false, false
+	}
+
+	got := argon2.IDKey([]byte(password), salt, iterations, memory, threads, uint32(len(want)))
+	if subtle.ConstantTimeCompare(got, want) != 1 {
+		return false, false
+	}
+	needsRehash = memory != argon2Memory || iterations != argon2Time || threads != argon2Threads || uint32(len(want)) != argon2KeyLen
+	return true, needsRehash
+}
+
+// verifyLegacyPassword 校验迁移前的 SHA-256 哈希，格式为 base64url(sha256(password+salt))$salt
+func verifyLegacyPassword(password, encoded string) bool {
+	parts := strings.Split(encoded, "$")
+	if len(parts) != 2 {
+		return false
+	}
 	hash := sha256.New()
-	hash.Write([]byte(password + salt))
-	return base64.URLEncoding.EncodeToString(hash.Sum(nil)) + "$" + salt
+	hash.Write([]byte(password + parts[1]))
+	want := base64.URLEncoding.EncodeToString(hash.Sum(nil))
+	return subtle.ConstantTimeCompare([]byte(want), []byte(parts[0])) == 1
 }
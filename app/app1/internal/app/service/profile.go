@@ -0,0 +1,231 @@
+package services
+
+import (
+	models "app/internal/app/model"
+	"errors"
+	"fmt"
+	"net/mail"
+	"net/url"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"gorm.io/gorm"
+)
+
+// DefaultTimezone 用户未设置时区时使用北京时间
+const DefaultTimezone = "Asia/Shanghai"
+
+// UserLocation 返回用户设置的时区，未设置或无效时使用 DefaultTimezone
+func UserLocation(user *models.User) *time.Location {
+	if user != nil && user.Timezone != "" {
+		if loc, err := time.LoadLocation(user.Timezone); err == nil {
+			return loc
+		}
+	}
+	loc, err := time.LoadLocation(DefaultTimezone)
+	if err != nil {
+		return time.Local
+	}
+	return loc
+}
+
+// MaxBioLength 个人简介最大字数
+const MaxBioLength = 500
+
+var (
+	ErrInvalidUsername  = errors.New("username cannot be empty")
+	ErrInvalidAvatarURL = errors.New("avatar URL must be an http or https URL")
+	ErrBioTooLong       = fmt.Errorf("bio cannot be longer than %d characters", MaxBioLength)
+	ErrInvalidTimezone  = errors.New("unknown timezone")
+	ErrInvalidLocale    = errors.New("locale must be zh-CN or en")
+	ErrInvalidEmail     = errors.New("invalid email address")
+	ErrWrongPassword    = errors.New("current password is incorrect")
+	ErrUserNotFound     = errors.New("user not found")
+)
+
+// IsProfileError 判断是否为资料校验错误，handler 据此返回 400
+func IsProfileError(err error) bool {
+	for _, target := range []error{ErrInvalidUsername, ErrInvalidAvatarURL, ErrBioTooLong,
+		ErrInvalidTimezone, ErrInvalidLocale, ErrInvalidEmail, ErrWeakPassword} {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// ProfileUpdate 修改资料的字段，为 nil 的字段保持不变
+type ProfileUpdate struct {
+	Username  *string
+	AvatarURL *string
+	Bio       *string
+	Timezone  *string
+	Locale    *string
+}
+
+// GetUser 按ID获取用户
+func (s *AuthService) GetUser(userID uint) (*models.User, error) {
+	var user models.User
+	if err := s.db.First(&user, userID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrUserNotFound
+		}
+		return nil, err
+	}
+	return &user, nil
+}
+
+// UpdateProfile 修改用户名、头像、简介、时区和语言
+func (s *AuthService) UpdateProfile(userID uint, update ProfileUpdate) (*models.User, error) {
+	user, err := s.GetUser(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	// 只写入修改的列，避免覆盖同时发生的经验、角色、封禁等变更
+	updates := map[string]interface{}{}
+	if update.Username != nil {
+		username := strings.TrimSpace(*update.Username)
+		if username == "" {
+			return nil, ErrInvalidUsername
+		}
+		if username != user.Username {
+			var count int64
+			if err := s.db.Model(&models.User{}).Where("username = ? AND id <> ?", username, userID).Count(&count).Error; err != nil {
+				return nil, err
+			}
+			if count > 0 {
+				return nil, ErrUserExists
+			}
+		}
+		updates["username"] = username
+	}
+	if update.AvatarURL != nil {
+		avatar := strings.TrimSpace(*update.AvatarURL)
+		if avatar != "" {
+			u, err := url.ParseRequestURI(avatar)
+			if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+				return nil, ErrInvalidAvatarURL
+			}
+		}
+		updates["avatar_url"] = avatar
+	}
+	if update.Bio != nil {
+		if utf8.RuneCountInString(*update.Bio) > MaxBioLength {
+			return nil, ErrBioTooLong
+		}
+		updates["bio"] = *update.Bio
+	}
+	if update.Timezone != nil {
+		if _, err := time.LoadLocation(*update.Timezone); err != nil || *update.Timezone == "" {
+			return nil, ErrInvalidTimezone
+		}
+		updates["timezone"] = *update.Timezone
+	}
+	if update.Locale != nil {
+		locale, ok := ParseLocale(*update.Locale)
+		if !ok {
+			return nil, ErrInvalidLocale
+		}
+		updates["locale"] = locale
+	}
+
+	if len(updates) > 0 {
+		if err := s.db.Model(user).Updates(updates).Error; err != nil {
+			if errors.Is(err, gorm.ErrDuplicatedKey) {
+				return nil, ErrUserExists
+			}
+			return nil, err
+		}
+	}
+	return user, nil
+}
+
+// ChangePassword 校验当前密码后设置新密码，并注销全部会话；
+// 只通过第三方登录注册的用户没有密码，可以直接设置
+func (s *AuthService) ChangePassword(userID uint, currentPassword, newPassword string) error {
+	user, err := s.GetUser(userID)
+	if err != nil {
+		return err
+	}
+	if user.Password != "" {
+		if ok, _ := verifyPassword(currentPassword, user.Password); !ok {
+			return ErrWrongPassword
+		}
+	}
+	if err := validatePassword(newPassword); err != nil {
+		return err
+	}
+
+	hashed, err := hashPassword(newPassword)
+	if err != nil {
+		return err
+	}
+	// 修改密码后所有设备都需要重新登录
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(user).Update("password", hashed).Error; err != nil {
+			return err
+		}
+		return revokeUserSessions(tx, userID)
+	})
+}
+
+// emailChangeKey 修改邮箱验证码按 “用户ID:新邮箱” 保存，其他用户无法使用
+func emailChangeKey(userID uint, email string) string {
+	return fmt.Sprintf("%d:%s", userID, email)
+}
+
+// RequestEmailChange 向新邮箱发送验证码
+func (s *AuthService) RequestEmailChange(userID uint, newEmail string) error {
+	newEmail = strings.TrimSpace(newEmail)
+	if _, err := mail.ParseAddress(newEmail); err != nil {
+		return ErrInvalidEmail
+	}
+	if err := s.checkEmailAvailable(userID, newEmail); err != nil {
+		return err
+	}
+	if err := s.limits.checkSendLimits(PurposeChangeEmail, newEmail); err != nil {
+		return err
+	}
+
+	user, err := s.GetUser(userID)
+	if err != nil {
+		return err
+	}
+	return s.issueCodeTo(PurposeChangeEmail, emailChangeKey(userID, newEmail), newEmail, user.Locale, VerificationCodeTTL)
+}
+
+// ConfirmEmailChange 校验新邮箱收到的验证码并修改邮箱
+func (s *AuthService) ConfirmEmailChange(userID uint, newEmail, code string) (*models.User, error) {
+	newEmail = strings.TrimSpace(newEmail)
+	if err := s.VerifyCode(PurposeChangeEmail, emailChangeKey(userID, newEmail), code); err != nil {
+		return nil, err
+	}
+	// 验证期间邮箱可能已被他人注册
+	if err := s.checkEmailAvailable(userID, newEmail); err != nil {
+		return nil, err
+	}
+
+	user, err := s.GetUser(userID)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.db.Model(user).Update("email", newEmail).Error; err != nil {
+		return nil, err
+	}
+	user.Email = newEmail
+	return user, nil
+}
+
+// checkEmailAvailable 检查邮箱是否已被其他用户使用
+func (s *AuthService) checkEmailAvailable(userID uint, email string) error {
+	var count int64
+	if err := s.db.Model(&models.User{}).Where("email = ? AND id <> ?", email, userID).Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 {
+		return ErrUserExists
+	}
+	return nil
+}
@@ -0,0 +1,219 @@
+package services
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// RateLimitError 请求过于频繁，RetryAfter 之后才能重试
+type RateLimitError struct {
+	RetryAfter time.Duration
+}
+
+func (e *RateLimitError) Error() string {
+	return fmt.Sprintf("too many requests, retry after %d seconds", int(e.RetryAfter.Seconds()+0.999))
+}
+
+type rateWindow struct {
+	start time.Time
+	count int
+}
+
+// RateLimiter 固定窗口计数限流器，每个 key 在 window 内最多允许 limit 次。
+// 计数保存在进程内存中，多实例部署时每个实例分别计数
+type RateLimiter struct {
+	mu        sync.Mutex
+	limit     int
+	window    time.Duration
+	hits      map[string]*rateWindow
+	lastSweep time.Time
+}
+
+// NewRateLimiter 创建限流器
+func NewRateLimiter(limit int, window time.Duration) *RateLimiter {
+	return &RateLimiter{limit: limit, window: window, hits: make(map[string]*rateWindow)}
+}
+
+// Allow 记录一次请求，超过限制时返回需要等待的时间
+func (l *RateLimiter) Allow(key string) (bool, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	l.sweep(now)
+
+	w, ok := l.hits[key]
+	if !ok || now.Sub(w.start) >= l.window {
+		w = &rateWindow{start: now}
+		l.hits[key] = w
+	}
+	if w.count >= l.limit {
+		return false, w.start.Add(l.window).Sub(now)
+	}
+	w.count++
+	return true, 0
+}
+
+// sweep 每个窗口清理一次已经过期的计数
+func (l *RateLimiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < l.window {
+		return
+	}
+	for key, w := range l.hits {
+		if now.Sub(w.start) >= l.window {
+			delete(l.hits, key)
+		}
+	}
+	l.lastSweep = now
+}
+
+type lockState struct {
+	failures    int
+	lastFailure time.Time
+	lockedUntil time.Time
+}
+
+// Lockout 连续失败 maxFailures 次后锁定 duration，成功一次即清零
+type Lockout struct {
+	mu          sync.Mutex
+	maxFailures int
+	duration    time.Duration
+	states      map[string]*lockState
+	lastSweep   time.Time
+}
+
+// NewLockout 创建失败锁定计数
+func NewLockout(maxFailures int, duration time.Duration) *Lockout {
+	return &Lockout{maxFailures: maxFailures, duration: duration, states: make(map[string]*lockState)}
+}
+
+// Check 返回剩余锁定时间，未锁定时返回 0
+func (l *Lockout) Check(key string) time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	state, ok := l.states[key]
+	if !ok {
+		return 0
+	}
+	if remaining := time.Until(state.lockedUntil); remaining > 0 {
+		return remaining
+	}
+	return 0
+}
+
+// Fail 记录一次失败，失败间隔超过锁定时长时重新计数
+func (l *Lockout) Fail(key string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	l.sweep(now)
+
+	state, ok := l.states[key]
+	if !ok || now.Sub(state.lastFailure) > l.duration {
+		state = &lockState{}
+		l.states[key] = state
+	}
+	state.failures++
+	state.lastFailure = now
+	if state.failures >= l.maxFailures {
+		state.lockedUntil = now.Add(l.duration)
+		state.failures = 0
+	}
+}
+
+// sweep 每个锁定时长清理一次已经解除锁定、失败计数也已过期的记录
+func (l *Lockout) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < l.duration {
+		return
+	}
+	for key, state := range l.states {
+		if now.Sub(state.lastFailure) > l.duration && !now.Before(state.lockedUntil) {
+			delete(l.states, key)
+		}
+	}
+	l.lastSweep = now
+}
+
+// Succeed 验证成功后清除失败记录
+func (l *Lockout) Succeed(key string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	delete(l.states, key)
+}
+
+// AuthLimits 验证码相关接口的限流配置
+type AuthLimits struct {
+	Email    *RateLimiter // 每个邮箱在窗口内最多收到的验证码数
+	Cooldown *RateLimiter // 同一邮箱同一用途两次发送之间的冷却时间
+	Failures *Lockout     // 验证码或密码连续输错后锁定
+}
+
+// RateLimitConfig 限流参数，零值表示使用默认值
+type RateLimitConfig struct {
+	IPRequests             int // 每个 IP 在窗口内最多请求发送验证码和登录的次数
+	IPWindow               time.Duration
+	EmailRequests          int // 每个邮箱在窗口内最多收到的验证码数
+	EmailWindow            time.Duration
+	ResendCooldown         time.Duration // 重新发送验证码的冷却时间
+	MaxFailedVerifications int           // 连续输错多少次后锁定
+	LockoutDuration        time.Duration
+}
+
+// withDefaults 补全未配置的参数
+func (c RateLimitConfig) withDefaults() RateLimitConfig {
+	if c.IPRequests <= 0 {
+		c.IPRequests = 20
+	}
+	if c.IPWindow <= 0 {
+		c.IPWindow = 10 * time.Minute
+	}
+	if c.EmailRequests <= 0 {
+		c.EmailRequests = 5
+	}
+	if c.EmailWindow <= 0 {
+		c.EmailWindow = time.Hour
+	}
+	if c.ResendCooldown <= 0 {
+		c.ResendCooldown = time.Minute
+	}
+	if c.MaxFailedVerifications <= 0 {
+		c.MaxFailedVerifications = 10
+	}
+	if c.LockoutDuration <= 0 {
+		c.LockoutDuration = 15 * time.Minute
+	}
+	return c
+}
+
+// NewAuthLimits 根据配置创建邮箱限流、冷却和失败锁定，同时返回按 IP 限流的限流器供中间件使用
+func NewAuthLimits(config RateLimitConfig) (*AuthLimits, *RateLimiter) {
+	config = config.withDefaults()
+	limits := &AuthLimits{
+		Email:    NewRateLimiter(config.EmailRequests, config.EmailWindow),
+		Cooldown: NewRateLimiter(1, config.ResendCooldown),
+		Failures: NewLockout(config.MaxFailedVerifications, config.LockoutDuration),
+	}
+	return limits, NewRateLimiter(config.IPRequests, config.IPWindow)
+}
+
+// checkSendLimits 发送验证码前检查冷却时间和邮箱限额
+func (l *AuthLimits) checkSendLimits(purpose CodePurpose, email string) error {
+	if ok, retry := l.Cooldown.Allow(string(purpose) + ":" + email); !ok {
+		return &RateLimitError{RetryAfter: retry}
+	}
+	if ok, retry := l.Email.Allow(email); !ok {
+		return &RateLimitError{RetryAfter: retry}
+	}
+	return nil
+}
+
+// checkLocked 账号处于锁定状态时返回错误
+func (l *AuthLimits) checkLocked(key string) error {
+	if remaining := l.Failures.Check(key); remaining > 0 {
+		return &RateLimitError{RetryAfter: remaining}
+	}
+	return nil
+}
@@ -0,0 +1,331 @@
+package services
+
+import (
+	models "app/internal/app/model"
+	"errors"
+	"log"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// RecurringTaskInterval 调度器检查周期任务的间隔，用户时区的零点过后最迟这么久生成当天的实例
+const RecurringTaskInterval = 10 * time.Minute
+
+// TaskTypeRecurring 周期任务实例的任务类型
+const TaskTypeRecurring = "recurring"
+
+// MaxStartDateYears 设置开始日期时最多早于或晚于今天的年数，连续打卡从开始日期逐天计算
+const MaxStartDateYears = 1
+
+var (
+	ErrRecurringTaskNotFound = errors.New("recurring task not found")
+	ErrInvalidStartDate      = errors.New("start_date must be a date such as 2024-01-31, within a year of today")
+)
+
+// NewRecurringTask 创建周期任务时可以设置的字段
+type NewRecurringTask struct {
+	Title       string
+	Description string
+	Points      int
+	Category    string
+	RRule       string
+	StartDate   string // 为空时为用户时区的今天
+}
+
+// RecurringTaskUpdate 修改周期任务的字段，为 nil 的字段保持不变；只影响之后生成的实例
+type RecurringTaskUpdate struct {
+	Title       *string
+	Description *string
+	Points      *int
+	Category    *string
+	RRule       *string
+	StartDate   *string
+	Active      *bool
+}
+
+// validateRecurringTask 校验并规范化周期任务的字段
+func validateRecurringTask(task *models.RecurringTask) error {
+	task.Title = strings.TrimSpace(task.Title)
+	if task.Title == "" || !validPoints(task.Points) {
+		return ErrInvalidTask
+	}
+	category, err := normalizeCategory(task.Category)
+	if err != nil {
+		return err
+	}
+	task.Category = category
+	rule, err := ParseRRule(task.RRule)
+	if err != nil {
+		return err
+	}
+	task.RRule = rule.String()
+	if _, err := time.Parse(DateLayout, task.StartDate); err != nil {
+		return ErrInvalidStartDate
+	}
+	return nil
+}
+
+// checkStartDate 检查新设置的开始日期距离用户时区的今天不超过 MaxStartDateYears 年，
+// 已有的开始日期不受限制
+func checkStartDate(startDate string, now time.Time, loc *time.Location) error {
+	start, err := time.Parse(DateLayout, startDate)
+	if err != nil {
+		return ErrInvalidStartDate
+	}
+	today := civilDate(now, loc)
+	if start.Before(today.AddDate(-MaxStartDateYears, 0, 0)) || start.After(today.AddDate(MaxStartDateYears, 0, 0)) {
+		return ErrInvalidStartDate
+	}
+	return nil
+}
+
+// CreateRecurringTask 创建周期任务，今天是发生日时立即生成今天的实例
+func (s *TaskService) CreateRecurringTask(userID uint, input NewRecurringTask) (*models.RecurringTask, error) {
+	var user models.User
+	if err := s.db.First(&user, userID).Error; err != nil {
+		return nil, err
+	}
+
+	task := models.RecurringTask{
+		UserID:      userID,
+		Title:       input.Title,
+		Description: input.Description,
+		Points:      input.Points,
+		Category:    input.Category,
+		RRule:       input.RRule,
+		StartDate:   strings.TrimSpace(input.StartDate),
+		Active:      true,
+	}
+	if task.StartDate == "" {
+		task.StartDate = civilDate(time.Now(), UserLocation(&user)).Format(DateLayout)
+	}
+	if err := validateRecurringTask(&task); err != nil {
+		return nil, err
+	}
+	if err := checkStartDate(task.StartDate, time.Now(), UserLocation(&user)); err != nil {
+		return nil, err
+	}
+
+	if err := s.db.Create(&task).Error; err != nil {
+		return nil, err
+	}
+	if err := s.generateOccurrence(&task, UserLocation(&user), time.Now()); err != nil {
+		return nil, err
+	}
+	return &task, nil
+}
+
+// ListRecurringTasks 列出用户的周期任务
+func (s *TaskService) ListRecurringTasks(userID uint) ([]models.RecurringTask, error) {
+	var tasks []models.RecurringTask
+	err := s.db.Where("user_id = ?", userID).Order("id").Find(&tasks).Error
+	return tasks, err
+}
+
+// getRecurringTask 获取用户自己的周期任务
+func (s *TaskService) getRecurringTask(id, userID uint) (*models.RecurringTask, error) {
+	var task models.RecurringTask
+	if err := s.db.Where("id = ? AND user_id = ?", id, userID).First(&task).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrRecurringTaskNotFound
+		}
+		return nil, err
+	}
+	return &task, nil
+}
+
+// UpdateRecurringTask 修改周期任务，已经生成的实例不受影响。
+// 只写入请求中出现的字段，不会覆盖调度器同时更新的 last_occurrence 和 active
+func (s *TaskService) UpdateRecurringTask(id, userID uint, update RecurringTaskUpdate) (*models.RecurringTask, error) {
+	task, err := s.getRecurringTask(id, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if update.Title != nil {
+		task.Title = *update.Title
+	}
+	if update.Description != nil {
+		task.Description = *update.Description
+	}
+	if update.Points != nil {
+		task.Points = *update.Points
+	}
+	if update.Category != nil {
+		task.Category = *update.Category
+	}
+	if update.RRule != nil {
+		task.RRule = *update.RRule
+	}
+	if update.StartDate != nil {
+		task.StartDate = strings.TrimSpace(*update.StartDate)
+	}
+	if update.Active != nil {
+		task.Active = *update.Active
+	}
+	if err := validateRecurringTask(task); err != nil {
+		return nil, err
+	}
+	if update.StartDate != nil {
+		var user models.User
+		if err := s.db.First(&user, userID).Error; err != nil {
+			return nil, err
+		}
+		if err := checkStartDate(task.StartDate, time.Now(), UserLocation(&user)); err != nil {
+			return nil, err
+		}
+	}
+
+	updates := map[string]interface{}{}
+	if update.Title != nil {
+		updates["title"] = task.Title
+	}
+	if update.Description != nil {
+		updates["description"] = task.Description
+	}
+	if update.Points != nil {
+		updates["points"] = task.Points
+	}
+	if update.Category != nil {
+		updates["category"] = task.Category
+	}
+	if update.RRule != nil {
+		updates["rrule"] = task.RRule
+	}
+	if update.StartDate != nil {
+		updates["start_date"] = task.StartDate
+	}
+	if update.Active != nil {
+		updates["active"] = task.Active
+	}
+	if len(updates) > 0 {
+		if err := s.db.Model(task).Updates(updates).Error; err != nil {
+			return nil, err
+		}
+	}
+	return task, nil
+}
+
+// DeleteRecurringTask 删除周期任务，已经生成的实例保留
+func (s *TaskService) DeleteRecurringTask(id, userID uint) error {
+	task, err := s.getRecurringTask(id, userID)
+	if err != nil {
+		return err
+	}
+	return s.db.Delete(task).Error
+}
+
+// NextOccurrence 返回周期任务在 from 当天或之后的下一个发生日，已结束时返回空字符串
+func NextOccurrence(task *models.RecurringTask, from time.Time, loc *time.Location) string {
+	rule, err := ParseRRule(task.RRule)
+	if err != nil {
+		return ""
+	}
+	start, err := time.Parse(DateLayout, task.StartDate)
+	if err != nil {
+		return ""
+	}
+	next, ok := rule.Next(start, civilDate(from, loc))
+	if !ok {
+		return ""
+	}
+	return next.Format(DateLayout)
+}
+
+// GenerateRecurringTasks 为每个启用的周期任务生成用户时区今天的实例，由调度器定期调用。
+// 服务停止期间错过的日期不补生成
+func (s *TaskService) GenerateRecurringTasks(now time.Time) error {
+	var tasks []models.RecurringTask
+	if err := s.db.Where("active = ?", true).Order("user_id").Find(&tasks).Error; err != nil {
+		return err
+	}
+
+	locations := make(map[uint]*time.Location)
+	for i := range tasks {
+		task := &tasks[i]
+		loc, ok := locations[task.UserID]
+		if !ok {
+			var user models.User
+			if err := s.db.First(&user, task.UserID).Error; err != nil {
+				if errors.Is(err, gorm.ErrRecordNotFound) {
+					continue
+				}
+				return err
+			}
+			loc = UserLocation(&user)
+			locations[task.UserID] = loc
+		}
+
+		// 单个任务失败不影响其他用户
+		if err := s.generateOccurrence(task, loc, now); err != nil {
+			log.Printf("generate recurring task %d: %v", task.ID, err)
+		}
+	}
+	return nil
+}
+
+// generateOccurrence 今天是发生日且尚未检查过时生成今天的实例；规则结束后停用周期任务
+func (s *TaskService) generateOccurrence(task *models.RecurringTask, loc *time.Location, now time.Time) error {
+	today := civilDate(now, loc)
+	todayStr := today.Format(DateLayout)
+	if task.LastOccurrence >= todayStr {
+		return nil
+	}
+
+	rule, err := ParseRRule(task.RRule)
+	if err != nil {
+		return err
+	}
+	start, err := time.Parse(DateLayout, task.StartDate)
+	if err != nil {
+		return err
+	}
+
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		// 以 last_occurrence 作为乐观锁，多个调度器同时运行时只有一个会生成实例
+		result := tx.Model(&models.RecurringTask{}).
+			Where("id = ? AND last_occurrence = ?", task.ID, task.LastOccurrence).
+			Update("last_occurrence", todayStr)
+		if result.Error != nil || result.RowsAffected == 0 {
+			return result.Error
+		}
+		task.LastOccurrence = todayStr
+
+		if rule.OccursOn(start, today) {
+			if err := tx.Create(&models.Task{
+				UserID:       task.UserID,
+				Title:        task.Title,
+				Description:  task.Description,
+				Points:       task.Points,
+				Category:     task.Category,
+				TaskType:     TaskTypeRecurring,
+				RecurrenceID: task.ID,
+				Occurrence:   todayStr,
+			}).Error; err != nil {
+				return err
+			}
+		}
+
+		if _, ok := rule.Next(start, today.AddDate(0, 0, 1)); !ok {
+			task.Active = false
+			return tx.Model(task).Update("active", false).Error
+		}
+		return nil
+	})
+}
+
+// recurringInstances 查询用户某一天的周期任务实例
+func recurringInstances(db *gorm.DB, userID uint, day string) ([]models.Task, error) {
+	var tasks []models.Task
+	if err := db.Where("user_id = ? AND recurrence_id <> 0 AND occurrence = ?", userID, day).
+		Order("id").Find(&tasks).Error; err != nil {
+		return nil, err
+	}
+	ptrs := make([]*models.Task, len(tasks))
+	for i := range tasks {
+		ptrs[i] = &tasks[i]
+	}
+	return tasks, loadTaskDetails(db, ptrs)
+}
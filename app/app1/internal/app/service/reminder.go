@@ -0,0 +1,221 @@
+package services
+
+import (
+	models "app/internal/app/model"
+	"errors"
+	"log"
+	"sort"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	// TaskReminderInterval 发送任务提醒的检查间隔
+	TaskReminderInterval = time.Minute
+	// MaxTaskReminders 每个任务最多设置的提醒数
+	MaxTaskReminders = 5
+	// MaxReminderMinutes 最早可以在截止前多少分钟提醒
+	MaxReminderMinutes = 30 * 24 * 60
+	// reminderBatchSize 每次检查最多发送的提醒数，剩下的下次检查再发送
+	reminderBatchSize = 500
+)
+
+// 通知类型，也是对应的邮件模板名称
+const (
+	NotificationTaskReminder = "task_reminder" // 截止前提醒
+	NotificationTaskOverdue  = "task_overdue"  // 已过截止时间仍未完成
+)
+
+var ErrInvalidReminders = errors.New("reminders must be at most 5 distinct minute offsets between 1 and 43200")
+
+// TaskDue 任务的截止时间，At 为空表示没有截止时间；AllDay 表示只有日期，At 为当天的 23:59:59
+type TaskDue struct {
+	At     *time.Time
+	AllDay bool
+}
+
+// EndOfDay 返回 day 所在日期在 loc 中的 23:59:59，作为只有日期的任务的截止时间
+func EndOfDay(day time.Time, loc *time.Location) time.Time {
+	y, m, d := day.Date()
+	return time.Date(y, m, d, 23, 59, 59, 0, loc)
+}
+
+// normalizeReminders 去重并从早到晚排列提醒，检查数量和范围
+func normalizeReminders(minutes []int) ([]int, error) {
+	result := make([]int, 0, len(minutes))
+	seen := make(map[int]bool)
+	for _, m := range minutes {
+		if m < 1 || m > MaxReminderMinutes {
+			return nil, ErrInvalidReminders
+		}
+		if !seen[m] {
+			seen[m] = true
+			result = append(result, m)
+		}
+	}
+	if len(result) > MaxTaskReminders {
+		return nil, ErrInvalidReminders
+	}
+	sort.Sort(sort.Reverse(sort.IntSlice(result)))
+	return result, nil
+}
+
+// taskOverdue 判断任务在 now 时是否已逾期
+func taskOverdue(task *models.Task, now time.Time) bool {
+	return !task.Completed && task.DueAt != nil && task.DueAt.Before(now)
+}
+
+// scheduleReminders 删除任务尚未发送的提醒，按当前的截止时间和提醒设置重新生成。
+// 已经过去的提醒时间不再生成，逾期通知在截止时间发送
+func scheduleReminders(tx *gorm.DB, task *models.Task, now time.Time) error {
+	if err := tx.Where("task_id = ? AND sent_at IS NULL", task.ID).Delete(&models.TaskReminder{}).Error; err != nil {
+		return err
+	}
+	if task.DueAt == nil || task.Completed || task.UserID == 0 || !task.DueAt.After(now) {
+		return nil
+	}
+
+	reminders := []models.TaskReminder{{
+		TaskID:   task.ID,
+		UserID:   task.UserID,
+		Kind:     NotificationTaskOverdue,
+		RemindAt: *task.DueAt,
+	}}
+	for _, minutes := range task.Reminders {
+		remindAt := task.DueAt.Add(-time.Duration(minutes) * time.Minute)
+		if remindAt.After(now) {
+			reminders = append(reminders, models.TaskReminder{
+				TaskID:   task.ID,
+				UserID:   task.UserID,
+				Kind:     NotificationTaskReminder,
+				RemindAt: remindAt,
+			})
+		}
+	}
+	return tx.Create(&reminders).Error
+}
+
+// SendTaskReminders 发送到期的任务提醒，由后台任务定期调用。每条提醒先标记为已发送再投递，
+// 多个实例同时运行时不会重复发送；单条提醒投递失败只记录日志
+func (s *AuthService) SendTaskReminders(now time.Time) error {
+	var reminders []models.TaskReminder
+	if err := s.db.Where("sent_at IS NULL AND remind_at <= ?", now).
+		Order("remind_at").Limit(reminderBatchSize).Find(&reminders).Error; err != nil {
+		return err
+	}
+
+	for i := range reminders {
+		reminder := &reminders[i]
+		result := s.db.Model(&models.TaskReminder{}).
+			Where("id = ? AND sent_at IS NULL", reminder.ID).Update("sent_at", now)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			continue
+		}
+		if err := s.deliverReminder(reminder, now); err != nil {
+			log.Printf("send task reminder %d: %v", reminder.ID, err)
+		}
+	}
+	return nil
+}
+
+// deliverReminder 写入站内通知并发送邮件。任务已完成、已删除或截止时间已过的截止前提醒直接跳过
+func (s *AuthService) deliverReminder(reminder *models.TaskReminder, now time.Time) error {
+	var task models.Task
+	if err := s.db.First(&task, reminder.TaskID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+		return err
+	}
+	if task.Completed || task.DueAt == nil {
+		return nil
+	}
+	if reminder.Kind == NotificationTaskReminder && !task.DueAt.After(now) {
+		return nil
+	}
+
+	user, err := s.GetUser(reminder.UserID)
+	if err != nil {
+		if errors.Is(err, ErrUserNotFound) {
+			return nil
+		}
+		return err
+	}
+
+	due := task.DueAt.In(UserLocation(user))
+	dueText := due.Format("2006-01-02 15:04")
+	if task.AllDay {
+		dueText = due.Format(DateLayout)
+	}
+	msg, err := s.templates.Render(reminder.Kind, user.Locale, user.Email, map[string]interface{}{
+		"Username": user.Username,
+		"Title":    task.Title,
+		"Due":      dueText,
+		"AllDay":   task.AllDay,
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := s.db.Create(&models.Notification{
+		UserID: user.ID,
+		Kind:   reminder.Kind,
+		Title:  msg.Subject,
+		TaskID: task.ID,
+	}).Error; err != nil {
+		return err
+	}
+	return s.mailer.Send(msg)
+}
+
+// 按截止时间划分的任务视图
+const (
+	DueToday    = "today"    // 截止时间在用户时区的今天
+	DueUpcoming = "upcoming" // 截止时间在明天起的若干天内
+	DueOverdue  = "overdue"  // 已过截止时间
+)
+
+const (
+	// DefaultUpcomingDays upcoming 视图默认包含的天数
+	DefaultUpcomingDays = 7
+	// MaxUpcomingDays upcoming 视图最多包含的天数
+	MaxUpcomingDays = 90
+)
+
+// ListDueTasks 按用户时区列出某个视图中未完成的个人任务，默认按截止时间排序；
+// days 只用于 upcoming 视图。query 中的其他筛选和分页参数照常生效
+func (s *TaskService) ListDueTasks(userID uint, view string, days int, query TaskQuery) (*TaskPage, error) {
+	var user models.User
+	if err := s.db.First(&user, userID).Error; err != nil {
+		return nil, err
+	}
+	now := time.Now().In(UserLocation(&user))
+	todayStart := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	tomorrowStart := todayStart.AddDate(0, 0, 1)
+
+	switch view {
+	case DueToday:
+		query.DueAfter, query.DueBefore = &todayStart, &tomorrowStart
+	case DueUpcoming:
+		if days < 1 {
+			days = DefaultUpcomingDays
+		}
+		if days > MaxUpcomingDays {
+			days = MaxUpcomingDays
+		}
+		end := tomorrowStart.AddDate(0, 0, days)
+		query.DueAfter, query.DueBefore = &tomorrowStart, &end
+	default:
+		query.DueAfter, query.DueBefore = nil, &now
+	}
+	completed := false
+	query.Completed = &completed
+	if query.Sort == "" {
+		query.Sort = "due_at"
+	}
+	return s.GetPersonalTasks(userID, query)
+}
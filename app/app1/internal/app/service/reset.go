@@ -0,0 +1,60 @@
+package services
+
+import (
+	models "app/internal/app/model"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// ResetTokenTTL 重置密码验证码的有效期
+const ResetTokenTTL = 30 * time.Minute
+
+// RequestPasswordReset 为邮箱对应的用户生成重置密码验证码并发送邮件。
+// 邮箱未注册时不发送也不报错，避免通过该接口探测账号是否存在
+func (s *AuthService) RequestPasswordReset(email string) error {
+	// 先限流再查用户，未注册的邮箱同样计数，避免通过 429 判断账号是否存在
+	if err := s.limits.checkSendLimits(PurposeReset, email); err != nil {
+		return err
+	}
+
+	var user models.User
+	if err := s.db.Where("email = ?", email).First(&user).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+		return err
+	}
+
+	return s.issueCode(PurposeReset, email, ResetTokenTTL)
+}
+
+// ResetPassword 校验并消费重置验证码，更新密码并注销该用户的全部会话
+func (s *AuthService) ResetPassword(email, code, newPassword string) error {
+	if err := validatePassword(newPassword); err != nil {
+		return err
+	}
+	if err := s.VerifyCode(PurposeReset, email, code); err != nil {
+		return err
+	}
+
+	var user models.User
+	if err := s.db.Where("email = ?", email).First(&user).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrCodeNotFound
+		}
+		return err
+	}
+
+	hashed, err := hashPassword(newPassword)
+	if err != nil {
+		return err
+	}
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&user).Update("password", hashed).Error; err != nil {
+			return err
+		}
+		return revokeUserSessions(tx, user.ID)
+	})
+}
@@ -0,0 +1,178 @@
+package services
+
+import (
+	models "app/internal/app/model"
+	"errors"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// 用户角色
+const (
+	RoleUser      = "user"
+	RoleModerator = "moderator"
+	RoleAdmin     = "admin"
+)
+
+// Permission 需要特定角色才能执行的操作
+type Permission string
+
+const (
+	PermViewUsers            Permission = "users:view"       // 搜索和查看其他用户的资料
+	PermSuspendUsers         Permission = "users:suspend"    // 暂停和恢复用户
+	PermBanUsers             Permission = "users:ban"        // 永久封禁和解封用户
+	PermManageUsers          Permission = "users:manage"     // 重置密码、调整经验
+	PermManageRoles          Permission = "users:role"       // 修改用户角色
+	PermViewAuditLogs        Permission = "audit:view"       // 查看审计日志
+	PermManageAdventures     Permission = "adventures:write" // 维护冒险任务池
+	PermDeleteCompletedTasks Permission = "tasks:purge"      // 删除所有用户的已完成任务
+)
+
+// rolePermissions 各角色拥有的权限，admin 拥有全部权限
+var rolePermissions = map[string][]Permission{
+	RoleModerator: {PermViewUsers, PermSuspendUsers},
+}
+
+var (
+	ErrInvalidRole = errors.New("role must be user, moderator or admin")
+	ErrUserBanned  = errors.New("this account has been banned")
+	ErrUserDeleted = errors.New("this account has been deleted")
+	ErrBanSelf     = errors.New("you cannot ban or change the role of yourself")
+	ErrOutranked   = errors.New("you can only act on users whose role is below yours")
+	ErrRoleTooHigh = errors.New("you cannot grant a role above your own")
+)
+
+// roleRanks 角色的高低，只能处理角色比自己低的用户
+var roleRanks = map[string]int{RoleUser: 0, RoleModerator: 1, RoleAdmin: 2}
+
+// UserRole 返回用户的角色，旧数据没有角色时视为普通用户
+func UserRole(user *models.User) string {
+	if user.Role == "" {
+		return RoleUser
+	}
+	return user.Role
+}
+
+// HasPermission 判断用户是否拥有指定权限
+func HasPermission(user *models.User, perm Permission) bool {
+	role := UserRole(user)
+	if role == RoleAdmin {
+		return true
+	}
+	for _, p := range rolePermissions[role] {
+		if p == perm {
+			return true
+		}
+	}
+	return false
+}
+
+// checkOutranks 检查操作者的角色是否高于目标用户，返回操作者
+func (s *AuthService) checkOutranks(operatorID uint, target *models.User) (*models.User, error) {
+	operator, err := s.GetUser(operatorID)
+	if err != nil {
+		return nil, err
+	}
+	if roleRanks[UserRole(operator)] <= roleRanks[UserRole(target)] {
+		return nil, ErrOutranked
+	}
+	return operator, nil
+}
+
+// IsUserBanned 判断用户当前是否处于封禁或暂停状态，暂停到期后自动恢复
+func IsUserBanned(user *models.User) bool {
+	return user.BannedAt != nil && (user.BannedUntil == nil || time.Now().Before(*user.BannedUntil))
+}
+
+// checkUserActive 被封禁或已注销的用户不能登录，也不能继续使用已有的令牌
+func checkUserActive(user *models.User) error {
+	if user.DeletedAt != nil {
+		return ErrUserDeleted
+	}
+	if IsUserBanned(user) {
+		return ErrUserBanned
+	}
+	return nil
+}
+
+// SetUserRole 修改用户角色，不能修改自己和角色不低于自己的用户的角色，也不能授予比自己高的角色
+func (s *AuthService) SetUserRole(operatorID, userID uint, role, reason string) (*models.User, error) {
+	if role != RoleUser && role != RoleModerator && role != RoleAdmin {
+		return nil, ErrInvalidRole
+	}
+	if operatorID == userID {
+		return nil, ErrBanSelf
+	}
+	user, err := s.GetUser(userID)
+	if err != nil {
+		return nil, err
+	}
+	operator, err := s.checkOutranks(operatorID, user)
+	if err != nil {
+		return nil, err
+	}
+	if roleRanks[role] > roleRanks[UserRole(operator)] {
+		return nil, ErrRoleTooHigh
+	}
+
+	previous := UserRole(user)
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(user).Update("role", role).Error; err != nil {
+			return err
+		}
+		return writeAudit(tx, operatorID, userID, AuditSetRole, strings.TrimSpace(reason), map[string]interface{}{
+			"from": previous,
+			"to":   role,
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	user.Role = role
+	return user, nil
+}
+
+// BanUser 永久封禁角色比自己低的用户并注销其全部会话，必须填写原因
+func (s *AuthService) BanUser(operatorID, userID uint, reason string) (*models.User, error) {
+	if operatorID == userID {
+		return nil, ErrBanSelf
+	}
+	reason = strings.TrimSpace(reason)
+	if reason == "" {
+		return nil, ErrReasonRequired
+	}
+	user, err := s.GetUser(userID)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := s.checkOutranks(operatorID, user); err != nil {
+		return nil, err
+	}
+	return s.restrictUser(operatorID, user, AuditBan, reason, nil)
+}
+
+// UnbanUser 解除角色比自己低的用户的封禁或暂停，必须填写原因
+func (s *AuthService) UnbanUser(operatorID, userID uint, reason string) (*models.User, error) {
+	reason = strings.TrimSpace(reason)
+	if reason == "" {
+		return nil, ErrReasonRequired
+	}
+	user, err := s.GetUser(userID)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := s.checkOutranks(operatorID, user); err != nil {
+		return nil, err
+	}
+	return s.liftRestriction(operatorID, user, AuditUnban, reason)
+}
+
+// PromoteAdmins 把配置中列出邮箱的用户设为管理员，用于初始化第一个管理员
+func (s *AuthService) PromoteAdmins(emails []string) error {
+	if len(emails) == 0 {
+		return nil
+	}
+	return s.db.Model(&models.User{}).Where("email IN ?", emails).Update("role", RoleAdmin).Error
+}
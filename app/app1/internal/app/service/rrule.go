@@ -0,0 +1,290 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DateLayout 周期任务中日期的格式
+const DateLayout = "2006-01-02"
+
+// maxRRuleScanDays 查找下一次发生日期时最多向后检查的天数
+const maxRRuleScanDays = 366 * 5
+
+var ErrInvalidRRule = errors.New("invalid rrule")
+
+// RRule 支持的 RFC 5545 RRULE 子集：
+// FREQ=DAILY|WEEKLY|MONTHLY，INTERVAL，BYDAY（仅 DAILY 和 WEEKLY，不带序号），
+// BYMONTHDAY（仅 MONTHLY，-1 表示最后一天），COUNT，UNTIL。周从周一开始
+type RRule struct {
+	Freq       string
+	Interval   int
+	ByDay      []time.Weekday
+	ByMonthDay []int
+	Count      int
+	Until      *time.Time
+}
+
+var rruleWeekdays = map[string]time.Weekday{
+	"MO": time.Monday, "TU": time.Tuesday, "WE": time.Wednesday, "TH": time.Thursday,
+	"FR": time.Friday, "SA": time.Saturday, "SU": time.Sunday,
+}
+
+func rruleError(format string, args ...interface{}) error {
+	return fmt.Errorf("%w: %s", ErrInvalidRRule, fmt.Sprintf(format, args...))
+}
+
+// ParseRRule 解析 RRULE，可以带 "RRULE:" 前缀
+func ParseRRule(value string) (*RRule, error) {
+	value = strings.TrimPrefix(strings.TrimSpace(strings.ToUpper(value)), "RRULE:")
+	if value == "" {
+		return nil, rruleError("rrule is required")
+	}
+
+	rule := &RRule{Interval: 1}
+	seen := make(map[string]bool)
+	for _, part := range strings.Split(value, ";") {
+		name, val, ok := strings.Cut(part, "=")
+		if !ok || val == "" {
+			return nil, rruleError("malformed part %q", part)
+		}
+		if seen[name] {
+			return nil, rruleError("%s appears more than once", name)
+		}
+		seen[name] = true
+
+		switch name {
+		case "FREQ":
+			if val != "DAILY" && val != "WEEKLY" && val != "MONTHLY" {
+				return nil, rruleError("FREQ must be DAILY, WEEKLY or MONTHLY")
+			}
+			rule.Freq = val
+		case "INTERVAL":
+			n, err := strconv.Atoi(val)
+			if err != nil || n < 1 || n > 366 {
+				return nil, rruleError("INTERVAL must be between 1 and 366")
+			}
+			rule.Interval = n
+		case "BYDAY":
+			for _, day := range strings.Split(val, ",") {
+				weekday, ok := rruleWeekdays[day]
+				if !ok {
+					return nil, rruleError("unsupported BYDAY value %q", day)
+				}
+				rule.ByDay = append(rule.ByDay, weekday)
+			}
+		case "BYMONTHDAY":
+			for _, day := range strings.Split(val, ",") {
+				n, err := strconv.Atoi(day)
+				if err != nil || n == 0 || n < -31 || n > 31 {
+					return nil, rruleError("BYMONTHDAY must be between 1 and 31, or -31 and -1")
+				}
+				rule.ByMonthDay = append(rule.ByMonthDay, n)
+			}
+		case "COUNT":
+			n, err := strconv.Atoi(val)
+			if err != nil || n < 1 {
+				return nil, rruleError("COUNT must be a positive integer")
+			}
+			rule.Count = n
+		case "UNTIL":
+			until, err := time.Parse("20060102", strings.SplitN(val, "T", 2)[0])
+			if err != nil {
+				return nil, rruleError("UNTIL must be a date such as 20240131")
+			}
+			rule.Until = &until
+		case "WKST":
+			if val != "MO" {
+				return nil, rruleError("only WKST=MO is supported")
+			}
+		default:
+			return nil, rruleError("unsupported part %s", name)
+		}
+	}
+
+	switch {
+	case rule.Freq == "":
+		return nil, rruleError("FREQ is required")
+	case rule.Count > 0 && rule.Until != nil:
+		return nil, rruleError("COUNT and UNTIL cannot be used together")
+	case len(rule.ByDay) > 0 && rule.Freq == "MONTHLY":
+		return nil, rruleError("BYDAY is not supported with FREQ=MONTHLY")
+	case len(rule.ByMonthDay) > 0 && rule.Freq != "MONTHLY":
+		return nil, rruleError("BYMONTHDAY requires FREQ=MONTHLY")
+	}
+	return rule, nil
+}
+
+// String 返回规范化的 RRULE
+func (r *RRule) String() string {
+	parts := []string{"FREQ=" + r.Freq}
+	if r.Interval > 1 {
+		parts = append(parts, "INTERVAL="+strconv.Itoa(r.Interval))
+	}
+	if len(r.ByDay) > 0 {
+		days := append([]time.Weekday(nil), r.ByDay...)
+		// 按周一到周日排序
+		sort.Slice(days, func(i, j int) bool { return (days[i]+6)%7 < (days[j]+6)%7 })
+		names := make([]string, 0, len(days))
+		for i, day := range days {
+			if i > 0 && day == days[i-1] {
+				continue
+			}
+			names = append(names, strings.ToUpper(day.String()[:2]))
+		}
+		parts = append(parts, "BYDAY="+strings.Join(names, ","))
+	}
+	if len(r.ByMonthDay) > 0 {
+		days := make([]string, len(r.ByMonthDay))
+		for i, day := range r.ByMonthDay {
+			days[i] = strconv.Itoa(day)
+		}
+		parts = append(parts, "BYMONTHDAY="+strings.Join(days, ","))
+	}
+	if r.Count > 0 {
+		parts = append(parts, "COUNT="+strconv.Itoa(r.Count))
+	}
+	if r.Until != nil {
+		parts = append(parts, "UNTIL="+r.Until.Format("20060102"))
+	}
+	return strings.Join(parts, ";")
+}
+
+// civilDate 返回 t 在 loc 中的日期，时间部分为 0，时区为 UTC，便于按天计算
+func civilDate(t time.Time, loc *time.Location) time.Time {
+	y, m, d := t.In(loc).Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+}
+
+func daysBetween(from, to time.Time) int {
+	return int(to.Sub(from).Hours() / 24)
+}
+
+// weekStart 返回 day 所在周的周一
+func weekStart(day time.Time) time.Time {
+	return day.AddDate(0, 0, -int((day.Weekday()+6)%7))
+}
+
+// matches 判断 day 是否符合规则，不考虑 COUNT 和 UNTIL；start 和 day 都是 civilDate
+func (r *RRule) matches(start, day time.Time) bool {
+	if day.Before(start) {
+		return false
+	}
+
+	switch r.Freq {
+	case "DAILY":
+		if daysBetween(start, day)%r.Interval != 0 {
+			return false
+		}
+		return len(r.ByDay) == 0 || r.hasWeekday(day.Weekday())
+	case "WEEKLY":
+		if (daysBetween(weekStart(start), weekStart(day))/7)%r.Interval != 0 {
+			return false
+		}
+		if len(r.ByDay) == 0 {
+			return day.Weekday() == start.Weekday()
+		}
+		return r.hasWeekday(day.Weekday())
+	default:
+		months := (day.Year()-start.Year())*12 + int(day.Month()-start.Month())
+		if months%r.Interval != 0 {
+			return false
+		}
+		monthDays := []int{start.Day()}
+		if len(r.ByMonthDay) > 0 {
+			monthDays = r.ByMonthDay
+		}
+		// 当月没有这一天时跳过，例如 31 号
+		lastDay := time.Date(day.Year(), day.Month()+1, 0, 0, 0, 0, 0, time.UTC).Day()
+		for _, target := range monthDays {
+			if target < 0 {
+				target = lastDay + 1 + target
+			}
+			if target == day.Day() {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+func (r *RRule) hasWeekday(weekday time.Weekday) bool {
+	for _, d := range r.ByDay {
+		if d == weekday {
+			return true
+		}
+	}
+	return false
+}
+
+// OccursOn 判断周期从 start 开始时 day 是否为发生日，start 和 day 都是 civilDate
+func (r *RRule) OccursOn(start, day time.Time) bool {
+	if !r.matches(start, day) {
+		return false
+	}
+	next, ok := r.Next(start, day)
+	return ok && next.Equal(day)
+}
+
+// Next 返回 from 当天或之后的第一个发生日，规则已结束时 ok 为 false
+func (r *RRule) Next(start, from time.Time) (next time.Time, ok bool) {
+	if from.Before(start) {
+		from = start
+	}
+	// 有 COUNT 时需要从 start 开始计数
+	day := from
+	if r.Count > 0 {
+		day = start
+	}
+	n := 0
+	for limit := daysBetween(day, from) + maxRRuleScanDays; limit > 0; limit-- {
+		if r.Until != nil && day.After(*r.Until) {
+			return time.Time{}, false
+		}
+		if r.matches(start, day) {
+			n++
+			if r.Count > 0 && n > r.Count {
+				return time.Time{}, false
+			}
+			if !day.Before(from) {
+				return day, true
+			}
+		}
+		day = day.AddDate(0, 0, 1)
+	}
+	return time.Time{}, false
+}
+
+// Occurrences 返回 [from, to] 之间的全部发生日
+func (r *RRule) Occurrences(start, from, to time.Time) []time.Time {
+	var days []time.Time
+	if from.Before(start) {
+		from = start
+	}
+	// 有 COUNT 时需要从 start 开始计数
+	day := from
+	if r.Count > 0 {
+		day = start
+	}
+	n := 0
+	for ; !day.After(to); day = day.AddDate(0, 0, 1) {
+		if r.Until != nil && day.After(*r.Until) {
+			break
+		}
+		if !r.matches(start, day) {
+			continue
+		}
+		n++
+		if r.Count > 0 && n > r.Count {
+			break
+		}
+		if !day.Before(from) {
+			days = append(days, day)
+		}
+	}
+	return days
+}
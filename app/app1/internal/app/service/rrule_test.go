@@ -0,0 +1,208 @@
+package services
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func date(t *testing.T, value string) time.Time {
+	t.Helper()
+	day, err := time.Parse(DateLayout, value)
+	if err != nil {
+		t.Fatalf("parse date %q: %v", value, err)
+	}
+	return day
+}
+
+func formatDays(days []time.Time) []string {
+	result := make([]string, len(days))
+	for i, day := range days {
+		result[i] = day.Format(DateLayout)
+	}
+	return result
+}
+
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestParseRRule(t *testing.T) {
+	tests := []struct {
+		value string
+		want  string
+	}{
+		{"FREQ=DAILY", "FREQ=DAILY"},
+		{"rrule:freq=daily;interval=1", "FREQ=DAILY"},
+		{"FREQ=DAILY;INTERVAL=3", "FREQ=DAILY;INTERVAL=3"},
+		{"FREQ=WEEKLY;BYDAY=FR,MO,MO", "FREQ=WEEKLY;BYDAY=MO,FR"},
+		{"FREQ=WEEKLY;BYDAY=SU,SA;WKST=MO", "FREQ=WEEKLY;BYDAY=SA,SU"},
+		{"FREQ=MONTHLY;BYMONTHDAY=1,-1", "FREQ=MONTHLY;BYMONTHDAY=1,-1"},
+		{"FREQ=DAILY;COUNT=10", "FREQ=DAILY;COUNT=10"},
+		{"FREQ=DAILY;UNTIL=20240131T235959Z", "FREQ=DAILY;UNTIL=20240131"},
+	}
+	for _, tt := range tests {
+		rule, err := ParseRRule(tt.value)
+		if err != nil {
+			t.Errorf("ParseRRule(%q) error: %v", tt.value, err)
+			continue
+		}
+		if got := rule.String(); got != tt.want {
+			t.Errorf("ParseRRule(%q).String() = %q, want %q", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestParseRRuleInvalid(t *testing.T) {
+	tests := []string{
+		"",
+		"RRULE:",
+		"INTERVAL=2",
+		"FREQ=YEARLY",
+		"FREQ=DAILY;FREQ=WEEKLY",
+		"FREQ=DAILY;INTERVAL",
+		"FREQ=DAILY;INTERVAL=0",
+		"FREQ=DAILY;INTERVAL=367",
+		"FREQ=WEEKLY;BYDAY=1MO",
+		"FREQ=WEEKLY;BYDAY=XX",
+		"FREQ=MONTHLY;BYDAY=MO",
+		"FREQ=DAILY;BYMONTHDAY=1",
+		"FREQ=MONTHLY;BYMONTHDAY=0",
+		"FREQ=MONTHLY;BYMONTHDAY=32",
+		"FREQ=MONTHLY;BYMONTHDAY=-32",
+		"FREQ=DAILY;COUNT=0",
+		"FREQ=DAILY;UNTIL=2024-01-31",
+		"FREQ=DAILY;COUNT=2;UNTIL=20240131",
+		"FREQ=DAILY;WKST=SU",
+		"FREQ=DAILY;BYHOUR=9",
+	}
+	for _, value := range tests {
+		if _, err := ParseRRule(value); !errors.Is(err, ErrInvalidRRule) {
+			t.Errorf("ParseRRule(%q) error = %v, want ErrInvalidRRule", value, err)
+		}
+	}
+}
+
+func TestRRuleOccurrences(t *testing.T) {
+	tests := []struct {
+		name  string
+		rule  string
+		start string
+		from  string
+		to    string
+		want  []string
+	}{
+		{"daily", "FREQ=DAILY", "2024-01-01", "2024-01-01", "2024-01-04",
+			[]string{"2024-01-01", "2024-01-02", "2024-01-03", "2024-01-04"}},
+		{"daily interval", "FREQ=DAILY;INTERVAL=3", "2024-01-01", "2024-01-01", "2024-01-09",
+			[]string{"2024-01-01", "2024-01-04", "2024-01-07"}},
+		{"daily interval from mid cycle", "FREQ=DAILY;INTERVAL=3", "2024-01-01", "2024-01-05", "2024-01-11",
+			[]string{"2024-01-07", "2024-01-10"}},
+		{"daily byday weekends", "FREQ=DAILY;BYDAY=SA,SU", "2024-01-01", "2024-01-01", "2024-01-14",
+			[]string{"2024-01-06", "2024-01-07", "2024-01-13", "2024-01-14"}},
+		{"weekly on start weekday", "FREQ=WEEKLY", "2024-01-03", "2024-01-01", "2024-01-20",
+			[]string{"2024-01-03", "2024-01-10", "2024-01-17"}},
+		{"weekly byday", "FREQ=WEEKLY;BYDAY=MO,WE,FR", "2024-01-01", "2024-01-01", "2024-01-07",
+			[]string{"2024-01-01", "2024-01-03", "2024-01-05"}},
+		{"weekly interval byday", "FREQ=WEEKLY;INTERVAL=2;BYDAY=TU,TH", "2024-01-03", "2024-01-01", "2024-01-21",
+			[]string{"2024-01-04", "2024-01-16", "2024-01-18"}},
+		{"monthly on start day skips short months", "FREQ=MONTHLY", "2024-01-31", "2024-01-01", "2024-05-31",
+			[]string{"2024-01-31", "2024-03-31", "2024-05-31"}},
+		{"monthly last day", "FREQ=MONTHLY;BYMONTHDAY=-1", "2024-01-15", "2024-01-01", "2024-04-30",
+			[]string{"2024-01-31", "2024-02-29", "2024-03-31", "2024-04-30"}},
+		{"monthly interval", "FREQ=MONTHLY;INTERVAL=2;BYMONTHDAY=1,15", "2024-01-01", "2024-01-01", "2024-05-31",
+			[]string{"2024-01-01", "2024-01-15", "2024-03-01", "2024-03-15", "2024-05-01", "2024-05-15"}},
+		{"count from start", "FREQ=DAILY;COUNT=3", "2024-01-01", "2024-01-02", "2024-01-10",
+			[]string{"2024-01-02", "2024-01-03"}},
+		{"count with byday", "FREQ=WEEKLY;BYDAY=MO,TH;COUNT=3", "2024-01-01", "2024-01-01", "2024-01-31",
+			[]string{"2024-01-01", "2024-01-04", "2024-01-08"}},
+		{"until inclusive", "FREQ=DAILY;UNTIL=20240103", "2024-01-01", "2024-01-01", "2024-01-10",
+			[]string{"2024-01-01", "2024-01-02", "2024-01-03"}},
+		{"range before start", "FREQ=DAILY", "2024-01-10", "2024-01-01", "2024-01-05", []string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rule, err := ParseRRule(tt.rule)
+			if err != nil {
+				t.Fatalf("ParseRRule(%q): %v", tt.rule, err)
+			}
+			got := formatDays(rule.Occurrences(date(t, tt.start), date(t, tt.from), date(t, tt.to)))
+			if !equalStrings(got, tt.want) {
+				t.Errorf("Occurrences = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRRuleNext(t *testing.T) {
+	tests := []struct {
+		name   string
+		rule   string
+		start  string
+		from   string
+		want   string
+		wantOK bool
+	}{
+		{"from before start", "FREQ=DAILY", "2024-01-10", "2024-01-01", "2024-01-10", true},
+		{"same day", "FREQ=WEEKLY;BYDAY=FR", "2024-01-01", "2024-01-05", "2024-01-05", true},
+		{"next week", "FREQ=WEEKLY;BYDAY=FR", "2024-01-01", "2024-01-06", "2024-01-12", true},
+		{"skipped week", "FREQ=WEEKLY;INTERVAL=2", "2024-01-01", "2024-01-02", "2024-01-15", true},
+		{"leap day", "FREQ=MONTHLY;BYMONTHDAY=-1", "2024-01-01", "2024-02-01", "2024-02-29", true},
+		{"non-leap year", "FREQ=MONTHLY;BYMONTHDAY=-1", "2023-01-01", "2023-02-01", "2023-02-28", true},
+		{"last counted occurrence", "FREQ=DAILY;COUNT=3", "2024-01-01", "2024-01-03", "2024-01-03", true},
+		{"count exhausted", "FREQ=DAILY;COUNT=3", "2024-01-01", "2024-01-04", "", false},
+		{"before until", "FREQ=DAILY;INTERVAL=2;UNTIL=20240105", "2024-01-01", "2024-01-04", "2024-01-05", true},
+		{"after until", "FREQ=DAILY;INTERVAL=2;UNTIL=20240105", "2024-01-01", "2024-01-06", "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rule, err := ParseRRule(tt.rule)
+			if err != nil {
+				t.Fatalf("ParseRRule(%q): %v", tt.rule, err)
+			}
+			next, ok := rule.Next(date(t, tt.start), date(t, tt.from))
+			if ok != tt.wantOK {
+				t.Fatalf("Next ok = %v, want %v", ok, tt.wantOK)
+			}
+			if ok && next.Format(DateLayout) != tt.want {
+				t.Errorf("Next = %s, want %s", next.Format(DateLayout), tt.want)
+			}
+		})
+	}
+}
+
+func TestRRuleOccursOn(t *testing.T) {
+	tests := []struct {
+		rule  string
+		start string
+		day   string
+		want  bool
+	}{
+		{"FREQ=DAILY;INTERVAL=2", "2024-01-01", "2024-01-03", true},
+		{"FREQ=DAILY;INTERVAL=2", "2024-01-01", "2024-01-04", false},
+		{"FREQ=DAILY", "2024-01-10", "2024-01-09", false},
+		{"FREQ=WEEKLY", "2024-01-01", "2024-01-08", true},
+		{"FREQ=WEEKLY", "2024-01-01", "2024-01-09", false},
+		{"FREQ=DAILY;COUNT=2", "2024-01-01", "2024-01-02", true},
+		{"FREQ=DAILY;COUNT=2", "2024-01-01", "2024-01-03", false},
+		{"FREQ=DAILY;UNTIL=20240102", "2024-01-01", "2024-01-03", false},
+		{"FREQ=MONTHLY;BYMONTHDAY=31", "2024-01-01", "2024-04-30", false},
+	}
+	for _, tt := range tests {
+		rule, err := ParseRRule(tt.rule)
+		if err != nil {
+			t.Fatalf("ParseRRule(%q): %v", tt.rule, err)
+		}
+		if got := rule.OccursOn(date(t, tt.start), date(t, tt.day)); got != tt.want {
+			t.Errorf("%s from %s: OccursOn(%s) = %v, want %v", tt.rule, tt.start, tt.day, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,235 @@
+package services
+
+import (
+	models "app/internal/app/model"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	// AccessTokenTTL 访问令牌有效期
+	AccessTokenTTL = 2 * time.Hour
+	// RefreshTokenTTL 刷新令牌有效期，移动端据此在重启后保持登录
+	RefreshTokenTTL = 30 * 24 * time.Hour
+	// sessionTouchInterval 最近使用时间的更新间隔，避免每个请求都写数据库
+	sessionTouchInterval = time.Minute
+	// maxDeviceLength 与 Session.Device 的列宽一致
+	maxDeviceLength = 255
+)
+
+var (
+	// ErrInvalidToken 令牌不存在、已过期或已注销
+	ErrInvalidToken = errors.New("invalid or expired token")
+	// ErrSessionNotFound 会话不存在、已注销或不属于当前用户
+	ErrSessionNotFound = errors.New("session not found")
+)
+
+// ClientInfo 发起请求的客户端，记录在会话中供用户识别登录设备
+type ClientInfo struct {
+	Device string
+	IP     string
+}
+
+// apply 把客户端信息写入会话并刷新最近使用时间
+func (c ClientInfo) apply(session *models.Session) {
+	device := c.Device
+	if len(device) > maxDeviceLength {
+		device = device[:maxDeviceLength]
+	}
+	if device != "" {
+		session.Device = device
+	}
+	session.IP = c.IP
+	session.LastSeenAt = time.Now()
+}
+
+// TokenPair 登录或刷新后返回给客户端的令牌
+type TokenPair struct {
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int    `json:"expires_in"` // 访问令牌剩余秒数
+}
+
+// generateToken 生成不透明的随机令牌
+func generateToken() string {
+	b := make([]byte, 32)
+	rand.Read(b)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// hashToken 数据库中只保存令牌的 SHA-256，泄露数据库也无法直接冒用会话
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// rotateTokens 为会话生成新的令牌对并写入会话记录
+func rotateTokens(session *models.Session) *TokenPair {
+	now := time.Now()
+	pair := &TokenPair{
+		AccessToken:  generateToken(),
+		RefreshToken: generateToken(),
+		TokenType:    "Bearer",
+		ExpiresIn:    int(AccessTokenTTL.Seconds()),
+	}
+	session.AccessTokenHash = hashToken(pair.AccessToken)
+	session.AccessExpiresAt = now.Add(AccessTokenTTL)
+	session.RefreshTokenHash = hashToken(pair.RefreshToken)
+	session.RefreshExpiresAt = now.Add(RefreshTokenTTL)
+	return pair
+}
+
+// CreateSession 为用户创建新的登录会话并签发令牌
+func (s *AuthService) CreateSession(userID uint, client ClientInfo) (*TokenPair, error) {
+	session := models.Session{UserID: userID}
+	client.apply(&session)
+	pair := rotateTokens(&session)
+	if err := s.db.Create(&session).Error; err != nil {
+		return nil, err
+	}
+	return pair, nil
+}
+
+// RefreshSession 用刷新令牌换取新的令牌对，旧的刷新令牌随即失效。
+// 被封禁或已注销的用户不能刷新；同一个刷新令牌同时刷新时只有一个请求成功
+func (s *AuthService) RefreshSession(refreshToken string, client ClientInfo) (*TokenPair, error) {
+	var session models.Session
+	oldHash := hashToken(refreshToken)
+	err := s.db.Where("refresh_token_hash = ? AND revoked_at IS NULL", oldHash).First(&session).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrInvalidToken
+		}
+		return nil, err
+	}
+	if time.Now().After(session.RefreshExpiresAt) {
+		return nil, ErrInvalidToken
+	}
+
+	var user models.User
+	if err := s.db.First(&user, session.UserID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrInvalidToken
+		}
+		return nil, err
+	}
+	if err := checkUserActive(&user); err != nil {
+		return nil, err
+	}
+
+	client.apply(&session)
+	pair := rotateTokens(&session)
+	result := s.db.Model(&models.Session{}).
+		Where("id = ? AND refresh_token_hash = ? AND revoked_at IS NULL", session.ID, oldHash).
+		Updates(map[string]interface{}{
+			"device":             session.Device,
+			"ip":                 session.IP,
+			"last_seen_at":       session.LastSeenAt,
+			"access_token_hash":  session.AccessTokenHash,
+			"access_expires_at":  session.AccessExpiresAt,
+			"refresh_token_hash": session.RefreshTokenHash,
+			"refresh_expires_at": session.RefreshExpiresAt,
+		})
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, ErrInvalidToken
+	}
+	return pair, nil
+}
+
+// AuthenticateToken 校验访问令牌，返回对应的会话
+func (s *AuthService) AuthenticateToken(accessToken string) (*models.Session, error) {
+	var session models.Session
+	err := s.db.Where("access_token_hash = ? AND revoked_at IS NULL", hashToken(accessToken)).First(&session).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrInvalidToken
+		}
+		return nil, err
+	}
+	if time.Now().After(session.AccessExpiresAt) {
+		return nil, ErrInvalidToken
+	}
+	return &session, nil
+}
+
+// RevokeSession 注销访问令牌所属的会话
+func (s *AuthService) RevokeSession(accessToken string) error {
+	session, err := s.AuthenticateToken(accessToken)
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	return s.db.Model(session).Update("revoked_at", &now).Error
+}
+
+// AuthenticateUser 校验访问令牌，返回令牌所属的用户和会话，并记录客户端的最近使用时间和 IP
+func (s *AuthService) AuthenticateUser(accessToken string, client ClientInfo) (*models.User, *models.Session, error) {
+	session, err := s.AuthenticateToken(accessToken)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var user models.User
+	if err := s.db.First(&user, session.UserID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil, ErrInvalidToken
+		}
+		return nil, nil, err
+	}
+	if err := checkUserActive(&user); err != nil {
+		return nil, nil, err
+	}
+
+	if time.Since(session.LastSeenAt) > sessionTouchInterval || session.IP != client.IP {
+		client.Device = "" // 设备以登录时为准
+		client.apply(session)
+		s.db.Model(session).Updates(map[string]interface{}{"ip": session.IP, "last_seen_at": session.LastSeenAt})
+	}
+	return &user, session, nil
+}
+
+// ListSessions 列出用户未注销且未过期的会话，最近使用的排在前面
+func (s *AuthService) ListSessions(userID uint) ([]models.Session, error) {
+	var sessions []models.Session
+	err := s.db.Where("user_id = ? AND revoked_at IS NULL AND refresh_expires_at > ?", userID, time.Now()).
+		Order("last_seen_at DESC").Find(&sessions).Error
+	return sessions, err
+}
+
+// RevokeUserSession 注销用户的某个会话，只能注销自己的会话
+func (s *AuthService) RevokeUserSession(userID, sessionID uint) error {
+	now := time.Now()
+	result := s.db.Model(&models.Session{}).
+		Where("id = ? AND user_id = ? AND revoked_at IS NULL", sessionID, userID).
+		Update("revoked_at", &now)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrSessionNotFound
+	}
+	return nil
+}
+
+// RevokeUserSessions 注销用户的全部会话
+func (s *AuthService) RevokeUserSessions(userID uint) error {
+	return revokeUserSessions(s.db, userID)
+}
+
+// revokeUserSessions 便于在事务中注销会话
+func revokeUserSessions(db *gorm.DB, userID uint) error {
+	now := time.Now()
+	return db.Model(&models.Session{}).
+		Where("user_id = ? AND revoked_at IS NULL", userID).
+		Update("revoked_at", &now).Error
+}
@@ -0,0 +1,119 @@
+package services
+
+import (
+	models "app/internal/app/model"
+	"errors"
+	"net/mail"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+var (
+	ErrUserExists     = errors.New("Username or email already exists")
+	ErrInvalidSignup  = errors.New("a valid email and a username are required")
+	ErrSignupNotFound = errors.New("registration not found or expired, please register again")
+)
+
+// StartRegistration 保存待验证的注册信息并向邮箱发送注册验证码
+func (s *AuthService) StartRegistration(email, username, password string) error {
+	email = strings.TrimSpace(email)
+	username = strings.TrimSpace(username)
+	if _, err := mail.ParseAddress(email); err != nil || username == "" {
+		return ErrInvalidSignup
+	}
+	if err := validatePassword(password); err != nil {
+		return err
+	}
+	if err := s.checkUserAvailable(s.db, email, username); err != nil {
+		return err
+	}
+
+	// 先检查限流，避免被限流时仍计算密码哈希、覆盖之前的注册信息
+	if err := s.limits.checkSendLimits(PurposeRegister, email); err != nil {
+		return err
+	}
+
+	hashed, err := hashPassword(password)
+	if err != nil {
+		return err
+	}
+
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		// 同一邮箱重新注册时覆盖之前的注册信息
+		if err := tx.Unscoped().Where("email = ?", email).Delete(&models.PendingSignup{}).Error; err != nil {
+			return err
+		}
+		return tx.Create(&models.PendingSignup{
+			Email:        email,
+			Username:     username,
+			PasswordHash: hashed,
+			ExpiresAt:    time.Now().Add(VerificationCodeTTL),
+		}).Error
+	})
+	if err != nil {
+		return err
+	}
+
+	return s.issueCode(PurposeRegister, email, VerificationCodeTTL)
+}
+
+// CompleteRegistration 校验注册验证码，用保存的注册信息创建用户，初始等级为 1、经验为 0
+func (s *AuthService) CompleteRegistration(email, code string) (*models.User, error) {
+	email = strings.TrimSpace(email)
+	if err := s.VerifyCode(PurposeRegister, email, code); err != nil {
+		return nil, err
+	}
+
+	var user models.User
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		var pending models.PendingSignup
+		if err := tx.Where("email = ?", email).First(&pending).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrSignupNotFound
+			}
+			return err
+		}
+		if time.Now().After(pending.ExpiresAt) {
+			return ErrSignupNotFound
+		}
+
+		// 验证期间用户名可能已被他人注册
+		if err := s.checkUserAvailable(tx, pending.Email, pending.Username); err != nil {
+			return err
+		}
+
+		user = models.User{
+			Email:    pending.Email,
+			Username: pending.Username,
+			Password: pending.PasswordHash,
+			Level:    1,
+			Locale:   DefaultLocale,
+		}
+		if err := tx.Create(&user).Error; err != nil {
+			// 检查之后、写入之前被同时注册的用户抢先
+			if errors.Is(err, gorm.ErrDuplicatedKey) {
+				return ErrUserExists
+			}
+			return err
+		}
+		return tx.Unscoped().Delete(&pending).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// checkUserAvailable 检查用户名或邮箱是否已被注册
+func (s *AuthService) checkUserAvailable(db *gorm.DB, email, username string) error {
+	var count int64
+	if err := db.Model(&models.User{}).Where("username = ? OR email = ?", username, email).Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 {
+		return ErrUserExists
+	}
+	return nil
+}
@@ -0,0 +1,233 @@
+package services
+
+import (
+	models "app/internal/app/model"
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// StreakConfig 保护卡和补打卡的参数，从用户的可用积分中扣除，未配置的项使用默认值
+type StreakConfig struct {
+	FreezeCost int // 每张保护卡的价格
+	MaxFreezes int // 最多同时持有的保护卡数量
+	FreezeDays int // 保护卡可以用在最近几天内错过的发生日，包括今天
+	MakeUpCost int // 每次补打卡的价格
+	MakeUpDays int // 可以补最近几天的打卡，1 表示只能补昨天
+}
+
+func (c StreakConfig) withDefaults() StreakConfig {
+	if c.FreezeCost <= 0 {
+		c.FreezeCost = 50
+	}
+	if c.MaxFreezes <= 0 {
+		c.MaxFreezes = 3
+	}
+	if c.FreezeDays <= 0 {
+		c.FreezeDays = 7
+	}
+	if c.MakeUpCost <= 0 {
+		c.MakeUpCost = 20
+	}
+	if c.MakeUpDays <= 0 {
+		c.MakeUpDays = 1
+	}
+	return c
+}
+
+var (
+	ErrInsufficientPoints = errors.New("not enough points")
+	ErrTooManyFreezes     = errors.New("cannot hold that many streak freezes")
+	ErrNoStreakFreeze     = errors.New("no streak freeze left")
+	ErrInvalidQuantity    = errors.New("quantity must be a positive integer")
+	ErrNotOccurrence      = errors.New("the recurring task does not occur on that date")
+	ErrAlreadyCheckedIn   = errors.New("that date is already checked in")
+	ErrOutsideWindow      = errors.New("that date is outside the allowed window")
+	ErrInvalidCheckInDate = errors.New("date must be a date such as 2024-01-31")
+)
+
+// StreakItems 用户持有的保护卡、可用积分和价格
+type StreakItems struct {
+	StreakFreezes int `json:"streak_freezes"`
+	Points        int `json:"points"` // 可用积分
+	FreezeCost    int `json:"freeze_cost"`
+	MaxFreezes    int `json:"max_freezes"`
+	FreezeDays    int `json:"freeze_days"`
+	MakeUpCost    int `json:"make_up_cost"`
+	MakeUpDays    int `json:"make_up_days"`
+}
+
+// spendPoints 在事务中扣除用户的可用积分并记录变动，积分不足时返回 ErrInsufficientPoints
+func spendPoints(tx *gorm.DB, userID uint, cost int, source, reason string) error {
+	result := tx.Model(&models.User{}).Where("id = ? AND points >= ?", userID, cost).
+		Update("points", gorm.Expr("points - ?", cost))
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrInsufficientPoints
+	}
+	return tx.Create(&models.ExperienceLog{
+		UserID: userID,
+		Track:  TrackPoints,
+		Delta:  -cost,
+		Source: source,
+		Reason: reason,
+	}).Error
+}
+
+// GetStreakItems 返回用户持有的保护卡和当前价格
+func (s *TaskService) GetStreakItems(userID uint) (*StreakItems, error) {
+	var user models.User
+	if err := s.db.First(&user, userID).Error; err != nil {
+		return nil, err
+	}
+	return &StreakItems{
+		StreakFreezes: user.StreakFreezes,
+		Points:        user.Points,
+		FreezeCost:    s.streaks.FreezeCost,
+		MaxFreezes:    s.streaks.MaxFreezes,
+		FreezeDays:    s.streaks.FreezeDays,
+		MakeUpCost:    s.streaks.MakeUpCost,
+		MakeUpDays:    s.streaks.MakeUpDays,
+	}, nil
+}
+
+// BuyStreakFreezes 用积分购买保护卡，持有数量不能超过上限
+func (s *TaskService) BuyStreakFreezes(userID uint, quantity int) (*StreakItems, error) {
+	if quantity < 1 {
+		return nil, ErrInvalidQuantity
+	}
+	if quantity > s.streaks.MaxFreezes {
+		return nil, ErrTooManyFreezes
+	}
+
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&models.User{}).Where("id = ? AND streak_freezes <= ?", userID, s.streaks.MaxFreezes-quantity).
+			Update("streak_freezes", gorm.Expr("streak_freezes + ?", quantity))
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrTooManyFreezes
+		}
+		return spendPoints(tx, userID, quantity*s.streaks.FreezeCost, ExperienceSourceStreakFreeze,
+			fmt.Sprintf("bought %d streak freeze(s)", quantity))
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s.GetStreakItems(userID)
+}
+
+// checkInTarget 读取用户的周期任务，并确认 date 是发生日，且在用户时区的今天之前 minAgo 到 maxAgo 天
+func (s *TaskService) checkInTarget(userID, habitID uint, date string, minAgo, maxAgo int) (*models.RecurringTask, time.Time, error) {
+	var user models.User
+	if err := s.db.First(&user, userID).Error; err != nil {
+		return nil, time.Time{}, err
+	}
+	habit, err := s.getRecurringTask(habitID, userID)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	today := civilDate(time.Now(), UserLocation(&user))
+	day, err := time.Parse(DateLayout, date)
+	if err != nil {
+		return nil, time.Time{}, ErrInvalidCheckInDate
+	}
+	if ago := daysBetween(day, today); ago < minAgo || ago > maxAgo {
+		return nil, time.Time{}, ErrOutsideWindow
+	}
+	rule, start, err := habitSchedule(habit)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	if !rule.OccursOn(start, day) {
+		return nil, time.Time{}, ErrNotOccurrence
+	}
+	return habit, today, nil
+}
+
+// UseStreakFreeze 用一张保护卡补上错过的发生日，连续打卡不中断，但这一天不计入连续次数
+func (s *TaskService) UseStreakFreeze(userID, habitID uint, date string) (*models.CheckIn, error) {
+	habit, today, err := s.checkInTarget(userID, habitID, date, 0, s.streaks.FreezeDays-1)
+	if err != nil {
+		return nil, err
+	}
+
+	checkIn := models.CheckIn{Date: date, Kind: CheckInFreeze}
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&models.User{}).Where("id = ? AND streak_freezes > 0", userID).
+			Update("streak_freezes", gorm.Expr("streak_freezes - 1"))
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrNoStreakFreeze
+		}
+		created, err := addCheckIn(tx, habit, checkIn, today)
+		if err == nil && !created {
+			return ErrAlreadyCheckedIn
+		}
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s.getCheckIn(habitID, date)
+}
+
+// MakeUpCheckIn 花积分补上最近错过的打卡，计入连续次数；当天的实例尚未完成时一并完成并发放奖励
+func (s *TaskService) MakeUpCheckIn(userID, habitID uint, date string) (*models.CheckIn, error) {
+	habit, today, err := s.checkInTarget(userID, habitID, date, 1, s.streaks.MakeUpDays)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		checkIn := models.CheckIn{Date: date, Kind: CheckInMakeUp, Cost: s.streaks.MakeUpCost}
+		var task models.Task
+		err := tx.Where("recurrence_id = ? AND occurrence = ?", habitID, date).First(&task).Error
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+		checkIn.TaskID = task.ID
+
+		// 先扣积分再打卡，补卡解锁的里程碑奖励不能用来支付补卡本身
+		if err := spendPoints(tx, userID, s.streaks.MakeUpCost, ExperienceSourceMakeUp,
+			fmt.Sprintf("make-up check-in on recurring task %d for %s", habitID, date)); err != nil {
+			return err
+		}
+		created, err := addCheckIn(tx, habit, checkIn, today)
+		if err != nil {
+			return err
+		}
+		if !created {
+			return ErrAlreadyCheckedIn
+		}
+
+		if task.ID == 0 || task.Completed {
+			return nil
+		}
+		completed, err := markTaskCompleted(tx, &task, time.Now())
+		if err != nil || !completed {
+			return err
+		}
+		return awardTaskExperience(tx, &task)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s.getCheckIn(habitID, date)
+}
+
+// getCheckIn 读取周期任务某一天的打卡记录
+func (s *TaskService) getCheckIn(habitID uint, date string) (*models.CheckIn, error) {
+	var checkIn models.CheckIn
+	if err := s.db.Where("recurring_task_id = ? AND date = ?", habitID, date).First(&checkIn).Error; err != nil {
+		return nil, err
+	}
+	return &checkIn, nil
+}
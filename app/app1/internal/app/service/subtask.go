@@ -0,0 +1,274 @@
+package services
+
+import (
+	models "app/internal/app/model"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// TaskTypeCombination 组合任务的类型，积分为子任务积分之和，全部子任务完成时自动完成
+const TaskTypeCombination = "combination"
+
+var (
+	ErrSubTaskNotFound     = errors.New("subtask not found")
+	ErrInvalidSubTaskOrder = errors.New("subtask_ids must list every subtask of the task exactly once")
+	ErrSubTasksIncomplete  = errors.New("a combination task is completed by completing all of its subtasks")
+	ErrSubTaskPoints       = fmt.Errorf("subtask points must add up to at most %d", MaxTaskPoints)
+	ErrCombinationPoints   = errors.New("the points of a combination task are the sum of its subtask points")
+)
+
+// SubTaskList 组合任务及其子任务，Progress 为按积分计算的完成百分比
+type SubTaskList struct {
+	Task            *models.Task     `json:"task"`
+	SubTasks        []models.SubTask `json:"subtasks"`
+	CompletedPoints int              `json:"completed_points"`
+	TotalPoints     int              `json:"total_points"`
+	Progress        int              `json:"progress"`
+}
+
+// SubTaskUpdate 修改子任务的字段，为 nil 的字段保持不变
+type SubTaskUpdate struct {
+	Title       *string
+	Description *string
+	Points      *int
+}
+
+// subTaskProgress 按子任务积分计算完成百分比；子任务都没有积分时按完成的个数计算
+func subTaskProgress(subTasks []models.SubTask) (completed, total, progress int) {
+	done := 0
+	for _, subTask := range subTasks {
+		total += subTask.Points
+		if subTask.Completed {
+			completed += subTask.Points
+			done++
+		}
+	}
+	switch {
+	case total > 0:
+		progress = completed * 100 / total
+	case len(subTasks) > 0:
+		progress = done * 100 / len(subTasks)
+	}
+	return completed, total, progress
+}
+
+// subTaskList 按顺序读取任务的子任务并计算进度
+func subTaskList(db *gorm.DB, task *models.Task) (*SubTaskList, error) {
+	list := &SubTaskList{Task: task, SubTasks: []models.SubTask{}}
+	if err := db.Where("task_id = ?", task.ID).Order("position, id").Find(&list.SubTasks).Error; err != nil {
+		return nil, err
+	}
+	list.CompletedPoints, list.TotalPoints, list.Progress = subTaskProgress(list.SubTasks)
+	return list, nil
+}
+
+// ListSubTasks 按顺序列出用户可以访问的任务的子任务和完成进度
+func (s *TaskService) ListSubTasks(taskID, userID uint) (*SubTaskList, error) {
+	task, err := s.GetTaskForUser(taskID, userID)
+	if err != nil {
+		return nil, err
+	}
+	return subTaskList(s.db, task)
+}
+
+// getSubTaskForUser 读取子任务并检查用户能否访问它所属的任务
+func (s *TaskService) getSubTaskForUser(subTaskID, userID uint) (*models.SubTask, *models.Task, error) {
+	var subTask models.SubTask
+	if err := s.db.First(&subTask, subTaskID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil, ErrSubTaskNotFound
+		}
+		return nil, nil, err
+	}
+	task, err := s.GetTaskForUser(subTask.TaskID, userID)
+	if err != nil {
+		if errors.Is(err, ErrTaskNotFound) {
+			return nil, nil, ErrSubTaskNotFound
+		}
+		return nil, nil, err
+	}
+	return &subTask, task, nil
+}
+
+// checkSubTasksCompleted 组合任务还有未完成的子任务时返回 ErrSubTasksIncomplete，
+// 组合任务只能通过完成全部子任务来完成
+func checkSubTasksCompleted(db *gorm.DB, task *models.Task) error {
+	if task.TaskType != TaskTypeCombination {
+		return nil
+	}
+	var open int64
+	if err := db.Model(&models.SubTask{}).Where("task_id = ? AND completed = ?", task.ID, false).
+		Count(&open).Error; err != nil {
+		return err
+	}
+	if open > 0 {
+		return ErrSubTasksIncomplete
+	}
+	return nil
+}
+
+// UpdateSubTask 修改子任务的标题、描述和积分，返回修改后的子任务列表。
+// 修改积分时父任务的积分同步为子任务积分之和，父任务的奖励已发放后不能再修改积分
+func (s *TaskService) UpdateSubTask(subTaskID, userID uint, update SubTaskUpdate) (*SubTaskList, error) {
+	subTask, task, err := s.getSubTaskForUser(subTaskID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	updates := map[string]interface{}{}
+	if update.Title != nil {
+		title := strings.TrimSpace(*update.Title)
+		if title == "" {
+			return nil, ErrInvalidTask
+		}
+		updates["title"] = title
+	}
+	if update.Description != nil {
+		updates["description"] = *update.Description
+	}
+	if update.Points != nil && *update.Points != subTask.Points {
+		if !validPoints(*update.Points) {
+			return nil, ErrInvalidTask
+		}
+		updates["points"] = *update.Points
+	}
+	if len(updates) == 0 {
+		return subTaskList(s.db, task)
+	}
+
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		// 锁住父任务，和完成子任务时发放奖励互斥
+		var parent models.Task
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&parent, task.ID).Error; err != nil {
+			return err
+		}
+		if _, ok := updates["points"]; !ok {
+			return tx.Model(subTask).Updates(updates).Error
+		}
+		if parent.Rewarded {
+			return ErrTaskRewarded
+		}
+
+		var total int
+		if err := tx.Model(&models.SubTask{}).Select("COALESCE(SUM(points), 0)").
+			Where("task_id = ? AND id <> ?", parent.ID, subTask.ID).Scan(&total).Error; err != nil {
+			return err
+		}
+		total += *update.Points
+		if total > MaxTaskPoints {
+			return ErrSubTaskPoints
+		}
+		if err := tx.Model(subTask).Updates(updates).Error; err != nil {
+			return err
+		}
+		return tx.Model(&parent).Update("points", total).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	task, err = s.GetTaskByID(task.ID)
+	if err != nil {
+		return nil, err
+	}
+	return subTaskList(s.db, task)
+}
+
+// ReorderSubTasks 按 subTaskIDs 的顺序重新排列任务的子任务，必须包含全部子任务且不能重复
+func (s *TaskService) ReorderSubTasks(taskID, userID uint, subTaskIDs []uint) (*SubTaskList, error) {
+	task, err := s.GetTaskForUser(taskID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		var existing []uint
+		if err := tx.Model(&models.SubTask{}).Where("task_id = ?", task.ID).Pluck("id", &existing).Error; err != nil {
+			return err
+		}
+		if len(existing) != len(subTaskIDs) {
+			return ErrInvalidSubTaskOrder
+		}
+		remaining := make(map[uint]bool, len(existing))
+		for _, id := range existing {
+			remaining[id] = true
+		}
+		for _, id := range subTaskIDs {
+			if !remaining[id] {
+				return ErrInvalidSubTaskOrder
+			}
+			delete(remaining, id)
+		}
+
+		for i, id := range subTaskIDs {
+			if err := tx.Model(&models.SubTask{}).Where("id = ?", id).Update("position", i+1).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return subTaskList(s.db, task)
+}
+
+// CompleteSubTask 把子任务标记为已完成。最后一个子任务完成时父任务自动完成，
+// 按当前的子任务积分之和发放奖励。重复完成同一个子任务不会报错
+func (s *TaskService) CompleteSubTask(subTaskID, userID uint) (*SubTaskList, error) {
+	subTask, task, err := s.getSubTaskForUser(subTaskID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		// 锁住父任务，同时完成最后几个子任务时只有一个事务能看到全部完成
+		var parent models.Task
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&parent, task.ID).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&models.SubTask{}).Where("id = ? AND completed = ?", subTask.ID, false).
+			Update("completed", true).Error; err != nil {
+			return err
+		}
+		if parent.Completed {
+			return nil
+		}
+
+		var subTasks []models.SubTask
+		if err := tx.Where("task_id = ?", parent.ID).Find(&subTasks).Error; err != nil {
+			return err
+		}
+		points := 0
+		for _, st := range subTasks {
+			if !st.Completed {
+				return nil
+			}
+			points += st.Points
+		}
+
+		parent.Points = points
+		if err := tx.Model(&parent).Update("points", parent.Points).Error; err != nil {
+			return err
+		}
+		completed, err := markTaskCompleted(tx, &parent, time.Now())
+		if err != nil || !completed {
+			return err
+		}
+		return onTaskCompleted(tx, &parent)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	task, err = s.GetTaskByID(task.ID)
+	if err != nil {
+		return nil, err
+	}
+	return subTaskList(s.db, task)
+}
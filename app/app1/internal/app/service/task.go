@@ -6,18 +6,134 @@ import (
 	"fmt"
 	"gorm.io/gorm"
 	"math/rand"
+	"strings"
 	"time"
 )
 
 type TaskService struct {
-	db *gorm.DB
-	DB *gorm.DB
+	db      *gorm.DB
+	DB      *gorm.DB
+	streaks StreakConfig
 }
 
 // NewTaskService 创建一个新的任务服务实例
 
-func NewTaskService(db *gorm.DB) *TaskService {
-	return &TaskService{db: db}
+func NewTaskService(db *gorm.DB, streaks StreakConfig) *TaskService {
+	return &TaskService{db: db, DB: db, streaks: streaks.withDefaults()}
+}
+
+// MaxTaskPoints 每个任务最多的积分，完成任务时按积分发放经验
+const MaxTaskPoints = 100
+
+var (
+	ErrTaskNotFound    = errors.New("task not found")
+	ErrTaskForbidden   = errors.New("task belongs to another user")
+	ErrInvalidTask     = fmt.Errorf("title cannot be empty and points must be between 0 and %d", MaxTaskPoints)
+	ErrTaskRewarded    = errors.New("points cannot be changed after the task has been rewarded")
+	ErrNotTeamMember   = errors.New("you are not a member of this team")
+	ErrInvalidCategory = errors.New("category must be self_improvement, work, habit or todo")
+	ErrInvalidTags     = fmt.Errorf("a task can have at most %d tags of up to %d characters each", MaxTaskTags, MaxTagLength)
+)
+
+// TaskCategories 任务分类，与升级所需的四项经验一一对应
+var TaskCategories = []string{TrackSelfImprovement, TrackWork, TrackHabit, TrackTodo}
+
+// NewTask 创建任务时可以设置的字段
+type NewTask struct {
+	Title       string
+	Description string
+	Points      int
+	Category    string // 为空时为 todo
+	Tags        []string
+	Due         TaskDue
+	Reminders   []int // 截止前多少分钟提醒
+}
+
+// TaskUpdate 修改任务的字段，为 nil 的字段保持不变
+type TaskUpdate struct {
+	Title       *string
+	Description *string
+	Points      *int
+	Completed   *bool
+	Category    *string
+	Tags        *[]string
+	Due         *TaskDue // At 为空时清除截止时间
+	Reminders   *[]int
+}
+
+// validPoints 判断任务积分是否在允许的范围内
+func validPoints(points int) bool {
+	return points >= 0 && points <= MaxTaskPoints
+}
+
+// normalizeCategory 校验任务分类，为空时使用 todo
+func normalizeCategory(category string) (string, error) {
+	category = strings.TrimSpace(category)
+	if category == "" {
+		return TrackTodo, nil
+	}
+	for _, c := range TaskCategories {
+		if c == category {
+			return category, nil
+		}
+	}
+	return "", ErrInvalidCategory
+}
+
+// awardTaskExperience 按任务分类发放完成奖励和同样多的可用积分，每个任务只发放一次。
+// 有贡献者的团队任务按贡献度分给贡献者，否则全部计入任务所属用户
+func awardTaskExperience(tx *gorm.DB, task *models.Task) error {
+	result := tx.Model(&models.Task{}).Where("id = ? AND rewarded = ?", task.ID, false).Update("rewarded", true)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil
+	}
+	task.Rewarded = true
+
+	track, err := normalizeCategory(task.Category)
+	if err != nil {
+		track = TrackTodo
+	}
+	reason := fmt.Sprintf("completed task %d", task.ID)
+	if len(task.Contributors) > 0 {
+		for userID, share := range task.Contributors {
+			if experience := int(float64(task.Points) * share); experience > 0 {
+				if err := addReward(tx, userID, track, experience, ExperienceSourceTask, reason); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	}
+	if task.UserID == 0 || task.Points <= 0 {
+		return nil
+	}
+	return addReward(tx, task.UserID, track, task.Points, ExperienceSourceTask, reason)
+}
+
+// onTaskCompleted 任务变为已完成时调用：发放完成奖励，周期任务实例记录打卡
+func onTaskCompleted(tx *gorm.DB, task *models.Task) error {
+	if err := awardTaskExperience(tx, task); err != nil {
+		return err
+	}
+	return recordCheckIn(tx, task, time.Now())
+}
+
+// markTaskCompleted 把未完成的任务标记为已完成并记录完成时间，任务已经完成时返回 false
+func markTaskCompleted(tx *gorm.DB, task *models.Task, now time.Time) (bool, error) {
+	result := tx.Model(&models.Task{}).Where("id = ? AND completed = ?", task.ID, false).
+		Updates(map[string]interface{}{"completed": true, "completed_at": &now})
+	if result.Error != nil {
+		return false, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return false, nil
+	}
+	task.Completed = true
+	task.CompletedAt = &now
+	return true, nil
 }
 
 func (s *TaskService) GetTaskByID(id uint) (*models.Task, error) {
@@ -25,142 +141,315 @@ func (s *TaskService) GetTaskByID(id uint) (*models.Task, error) {
 	result := s.DB.First(&task, id) // GORM 使用 First 方法查找第一个匹配的记录
 	if result.Error != nil {
 		if result.Error == gorm.ErrRecordNotFound {
-			return nil, errors.New("task not found")
+			return nil, ErrTaskNotFound
 		}
 		return nil, result.Error
 	}
 
+	if err := loadTaskDetails(s.db, []*models.Task{&task}); err != nil {
+		return nil, err
+	}
 	return &task, nil
 }
 
-func (s *TaskService) CreateTask(userID uint, title string, description string, points int) error {
+// GetTaskForUser 获取用户有权访问的任务：自己创建的任务，或所在团队的团队任务
+func (s *TaskService) GetTaskForUser(taskID, userID uint) (*models.Task, error) {
+	task, err := s.GetTaskByID(taskID)
+	if err != nil {
+		return nil, err
+	}
+	if task.UserID == userID {
+		return task, nil
+	}
+	if task.TeamID != 0 {
+		member, err := s.IsTeamMember(task.TeamID, userID)
+		if err != nil {
+			return nil, err
+		}
+		if member {
+			return task, nil
+		}
+	}
+	return nil, ErrTaskForbidden
+}
+
+// IsTeamMember 判断用户是否已加入团队
+func (s *TaskService) IsTeamMember(teamID, userID uint) (bool, error) {
+	var count int64
+	err := s.db.Model(&models.TeamMember{}).Where("team_id = ? AND user_id = ?", teamID, userID).Count(&count).Error
+	return count > 0, err
+}
+
+// CreateTask 创建个人任务，返回保存后的任务
+func (s *TaskService) CreateTask(userID uint, input NewTask) (*models.Task, error) {
 	// 参数验证
 	if userID == 0 {
-		return errors.New("invalid user ID")
+		return nil, errors.New("invalid user ID")
 	}
-	if title == "" {
-		return errors.New("title cannot be empty")
+	if strings.TrimSpace(input.Title) == "" || !validPoints(input.Points) {
+		return nil, ErrInvalidTask
 	}
-	if points < 0 {
-		return errors.New("points must be non-negative")
+	category, err := normalizeCategory(input.Category)
+	if err != nil {
+		return nil, err
+	}
+	tags, err := normalizeTags(input.Tags)
+	if err != nil {
+		return nil, err
+	}
+	reminders, err := normalizeReminders(input.Reminders)
+	if err != nil {
+		return nil, err
 	}
 
 	// 创建 Task 实例
 	newTask := models.Task{
 		UserID:      userID,
-		Title:       title,
-		Description: description,
-		Points:      points,
+		Title:       strings.TrimSpace(input.Title),
+		Description: input.Description,
+		Points:      input.Points,
 		Completed:   false, // 默认任务未完成
+		TaskType:    "personal",
+		Category:    category,
+		Tags:        tags,
+		DueAt:       input.Due.At,
+		AllDay:      input.Due.At != nil && input.Due.AllDay,
+		Reminders:   reminders,
 	}
 
-	// 将新任务保存到数据库
-	if err := s.DB.Create(&newTask).Error; err != nil {
-		return err // 如果数据库操作出错，则返回错误
+	// 将新任务、标签和提醒保存到数据库
+	now := time.Now()
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&newTask).Error; err != nil {
+			return err
+		}
+		if err := setTaskTags(tx, newTask.ID, tags); err != nil {
+			return err
+		}
+		return scheduleReminders(tx, &newTask, now)
+	})
+	if err != nil {
+		return nil, err // 如果数据库操作出错，则返回错误
 	}
 
-	return nil // 如果没有错误发生，返回 nil
+	newTask.Overdue = taskOverdue(&newTask, now)
+	return &newTask, nil
 }
 
-func (s *TaskService) DeleteTask(taskID uint) error {
+// DeleteTask 删除用户有权访问的任务及其子任务
+func (s *TaskService) DeleteTask(taskID, userID uint) error {
 	// 查询要删除的任务
-	var task models.Task
-	if err := s.db.First(&task, taskID).Error; err != nil {
+	task, err := s.GetTaskForUser(taskID, userID)
+	if err != nil {
 		return err
 	}
 
 	// 从数据库中删除任务
-	if err := s.db.Delete(&task).Error; err != nil {
-		return err
-	}
-
-	return nil
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("task_id = ?", task.ID).Delete(&models.SubTask{}).Error; err != nil {
+			return err
+		}
+		if err := setTaskTags(tx, task.ID, nil); err != nil {
+			return err
+		}
+		if err := tx.Where("task_id = ?", task.ID).Delete(&models.TaskReminder{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(task).Error
+	})
 }
 
-func (s *TaskService) UpdateTask(taskID uint, title, description string, points int, completed bool) error {
+// UpdateTask 修改用户有权访问的任务，返回修改后的任务
+func (s *TaskService) UpdateTask(taskID, userID uint, update TaskUpdate) (*models.Task, error) {
 	// 查询要更新的任务
-	var task models.Task
-	if err := s.db.First(&task, taskID).Error; err != nil {
-		return err
+	task, err := s.GetTaskForUser(taskID, userID)
+	if err != nil {
+		return nil, err
 	}
 
-	// 更新任务属性
-	task.Title = title
-	task.Description = description
-	task.Points = points
-	task.Completed = completed
+	// 更新任务属性，只写入修改的字段，不覆盖同时进行的完成和发放奖励
+	var columns []string
+	if update.Title != nil {
+		task.Title = strings.TrimSpace(*update.Title)
+		if task.Title == "" {
+			return nil, ErrInvalidTask
+		}
+		columns = append(columns, "title")
+	}
+	if update.Description != nil {
+		task.Description = *update.Description
+		columns = append(columns, "description")
+	}
+	changePoints := update.Points != nil && *update.Points != task.Points
+	if changePoints {
+		if !validPoints(*update.Points) {
+			return nil, ErrInvalidTask
+		}
+		if task.TaskType == TaskTypeCombination {
+			return nil, ErrCombinationPoints
+		}
+		task.Points = *update.Points
+	}
+	completing := update.Completed != nil && *update.Completed && !task.Completed
+	if completing {
+		if err := checkSubTasksCompleted(s.db, task); err != nil {
+			return nil, err
+		}
+	}
+	if update.Completed != nil {
+		task.Completed = *update.Completed
+		if !task.Completed {
+			task.CompletedAt = nil
+			columns = append(columns, "completed", "completed_at")
+		}
+	}
+	if update.Category != nil {
+		if task.Category, err = normalizeCategory(*update.Category); err != nil {
+			return nil, err
+		}
+		columns = append(columns, "category")
+	}
+	if update.Tags != nil {
+		if task.Tags, err = normalizeTags(*update.Tags); err != nil {
+			return nil, err
+		}
+	}
+	if update.Due != nil {
+		task.DueAt = update.Due.At
+		task.AllDay = update.Due.At != nil && update.Due.AllDay
+		columns = append(columns, "due_at", "all_day")
+	}
+	if update.Reminders != nil {
+		if task.Reminders, err = normalizeReminders(*update.Reminders); err != nil {
+			return nil, err
+		}
+		columns = append(columns, "reminders")
+	}
 
 	// 保存更新后的任务到数据库
-	if err := s.db.Save(&task).Error; err != nil {
-		return err
+	now := time.Now()
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		// 用 Select 指定列而不是 map，reminders 才会按 serializer 写入
+		if len(columns) > 0 {
+			if err := tx.Model(task).Select(append(columns, "updated_at")).Updates(task).Error; err != nil {
+				return err
+			}
+		}
+		// 奖励已经发放的任务不能再修改积分，以数据库中的 rewarded 为准
+		if changePoints {
+			result := tx.Model(&models.Task{}).Where("id = ? AND rewarded = ?", task.ID, false).
+				Update("points", task.Points)
+			if result.Error != nil {
+				return result.Error
+			}
+			if result.RowsAffected == 0 {
+				return ErrTaskRewarded
+			}
+		}
+		if completing {
+			// 同时有其他请求完成了任务时由它发放奖励
+			if completing, err = markTaskCompleted(tx, task, now); err != nil {
+				return err
+			}
+		}
+		if update.Tags != nil {
+			if err := setTaskTags(tx, task.ID, task.Tags); err != nil {
+				return err
+			}
+		}
+		if update.Due != nil || update.Reminders != nil || update.Completed != nil {
+			if err := scheduleReminders(tx, task, now); err != nil {
+				return err
+			}
+		}
+		if completing {
+			return onTaskCompleted(tx, task)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
 	}
 
-	return nil
+	task.Overdue = taskOverdue(task, now)
+	return task, nil
 }
 
-func (s *TaskService) GetPersonalTasks(userID uint) ([]models.Task, error) {
-	var tasks []models.Task
-	if err := s.db.Where("user_id = ?", userID).Find(&tasks).Error; err != nil {
-		return nil, err
-	}
-	return tasks, nil
+// GetPersonalTasks 分页列出用户创建的任务
+func (s *TaskService) GetPersonalTasks(userID uint, query TaskQuery) (*TaskPage, error) {
+	return s.listTasks(func(db *gorm.DB) *gorm.DB { return db.Where("user_id = ?", userID) }, query)
 }
 
-func (s *TaskService) GetTeamTasks(teamID uint) ([]models.Task, error) {
-	var tasks []models.Task
-	if err := s.db.Where("team_id = ?", teamID).Find(&tasks).Error; err != nil {
-		return nil, err
-	}
-	return tasks, nil
+// GetTeamTasks 分页列出团队任务
+func (s *TaskService) GetTeamTasks(teamID uint, query TaskQuery) (*TaskPage, error) {
+	return s.listTasks(func(db *gorm.DB) *gorm.DB { return db.Where("team_id = ?", teamID) }, query)
 }
 
-func (s *TaskService) GetRandomAdventureTask() (*models.Task, error) {
-	var task models.Task
-	// 示例：从数据库中随机获取一个冒险类型的任务
-	// 这里的查询条件和逻辑需要根据你的具体情况调整
-	err := s.DB.Where("type = ?", "adventure").Order("RAND()").First(&task).Error
+// CreateCombinationTask 创建组合任务及其子任务，返回创建的任务和按顺序排列的子任务。
+// 子任务一律以未完成状态创建，至少需要一个子任务
+func (s *TaskService) CreateCombinationTask(userID uint, title string, description string, category string, subTasks []models.SubTask) (*models.Task, error) {
+	title = strings.TrimSpace(title)
+	if title == "" || len(subTasks) == 0 {
+		return nil, ErrInvalidTask
+	}
+	category, err := normalizeCategory(category)
 	if err != nil {
 		return nil, err
 	}
-	return &task, nil
-}
 
-func (s *TaskService) CreateCombinationTask(userID uint, title string, description string, subTasks []models.SubTask) error {
+	// 组合任务的积分为子任务积分之和，全部子任务完成后自动完成并发放
+	points := 0
+	for _, subTask := range subTasks {
+		if !validPoints(subTask.Points) {
+			return nil, ErrInvalidTask
+		}
+		points += subTask.Points
+	}
+	if points > MaxTaskPoints {
+		return nil, ErrSubTaskPoints
+	}
+
 	// 创建组合任务实例
 	newCombinationTask := models.Task{
 		// 设置组合任务的属性
-		TaskType:    "combination",
+		TaskType:    TaskTypeCombination,
 		UserID:      userID,
 		Title:       title,
 		Description: description,
-		// 其他属性根据实际情况设置
-	}
-
-	// 将组合任务保存到数据库
-	if err := s.db.Create(&newCombinationTask).Error; err != nil {
-		return err
+		Category:    category,
+		Points:      points,
 	}
 
-	// 保存组合任务的ID以及所有子任务的关联关系到数据库
-	for _, subTask := range subTasks {
-		// 创建子任务关联关系实例
-		taskSubTask := models.SubTask{
-			TaskID:      newCombinationTask.ID,
-			Title:       subTask.Title,
-			Description: subTask.Description,
-			Points:      subTask.Points,
-			Completed:   subTask.Completed,
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		// 将组合任务保存到数据库
+		if err := tx.Create(&newCombinationTask).Error; err != nil {
+			return err
 		}
 
-		// 将子任务关联关系保存到数据库
-		if err := s.db.Create(&taskSubTask).Error; err != nil {
-			return err
+		// 保存组合任务的ID以及所有子任务的关联关系到数据库，按提交的顺序排列
+		for i, subTask := range subTasks {
+			taskSubTask := models.SubTask{
+				TaskID:      newCombinationTask.ID,
+				Title:       subTask.Title,
+				Description: subTask.Description,
+				Points:      subTask.Points,
+				Completed:   false,
+				Position:    i + 1,
+			}
+			if err := tx.Create(&taskSubTask).Error; err != nil {
+				return err
+			}
+			newCombinationTask.SubTasks = append(newCombinationTask.SubTasks, taskSubTask)
 		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
 	}
-
-	return nil
+	return &newCombinationTask, nil
 }
 
+// MarkTaskAsCompleted 标记任务为已完成，并按任务分类发放经验
 func (s *TaskService) MarkTaskAsCompleted(taskID uint) error {
 	// 查询要标记为已完成的任务
 	var task models.Task
@@ -168,15 +457,22 @@ func (s *TaskService) MarkTaskAsCompleted(taskID uint) error {
 		return err
 	}
 
-	// 将任务标记为已完成
-	task.Completed = true
-
-	// 保存更新后的任务到数据库
-	if err := s.db.Save(&task).Error; err != nil {
+	// 已经完成的任务不再重复发放
+	if task.Completed {
+		return nil
+	}
+	if err := checkSubTasksCompleted(s.db, &task); err != nil {
 		return err
 	}
 
-	return nil
+	// 将任务标记为已完成并发放奖励
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		completed, err := markTaskCompleted(tx, &task, time.Now())
+		if err != nil || !completed {
+			return err
+		}
+		return onTaskCompleted(tx, &task)
+	})
 }
 
 func (s *TaskService) DeleteCompletedTasks() error {
@@ -196,6 +492,7 @@ func (s *TaskService) DeleteCompletedTasks() error {
 	return nil
 }
 
+// CompleteTeamTask 完成团队任务，按贡献度把经验计入贡献者对应分类的经验
 func (s *TaskService) CompleteTeamTask(taskID uint, level int) error {
 	// 查询团队任务
 	var task models.Task
@@ -203,21 +500,18 @@ func (s *TaskService) CompleteTeamTask(taskID uint, level int) error {
 		return err
 	}
 
-	// 分配经验值给贡献者
-	for memberID, percentage := range task.Contributors {
-		// 计算贡献的经验值
-		experience := int(float64(task.Points) * percentage)
-		// 此处省略了对用户添加经验值的逻辑，需要根据实际情况实现
-		fmt.Printf("Adding %d experience points to user with ID %d\n", experience, memberID)
-	}
-
-	// 标记团队任务为已完成
-	task.Completed = true
-	if err := s.db.Save(&task).Error; err != nil {
-		return err
+	if task.Completed {
+		return nil
 	}
 
-	return nil
+	// 标记团队任务为已完成并分配经验值给贡献者
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		completed, err := markTaskCompleted(tx, &task, time.Now())
+		if err != nil || !completed {
+			return err
+		}
+		return onTaskCompleted(tx, &task)
+	})
 }
 
 func (s *TaskService) GetUserByID(userID uint) (*models.User, error) {
@@ -246,18 +540,16 @@ func (s *TaskService) AddExperience(userID uint, experience int) error {
 }
 
 func (s *TaskService) CalculateCompletionPercentage(userID uint) (float64, error) {
-	allTasks, err := s.GetPersonalTasks(userID)
-	if err != nil {
+	var totalTasks, completedTasks int64
+	if err := s.db.Model(&models.Task{}).Where("user_id = ?", userID).Count(&totalTasks).Error; err != nil {
 		return 0, err
 	}
-
-	completedTasks := 0
-	totalTasks := len(allTasks)
-
-	for _, task := range allTasks {
-		if task.Completed {
-			completedTasks++
-		}
+	if err := s.db.Model(&models.Task{}).Where("user_id = ? AND completed = ?", userID, true).
+		Count(&completedTasks).Error; err != nil {
+		return 0, err
+	}
+	if totalTasks == 0 {
+		return 0, nil
 	}
 
 	percentage := float64(completedTasks) / float64(totalTasks) * 100
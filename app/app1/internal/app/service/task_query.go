@@ -0,0 +1,307 @@
+package services
+
+import (
+	models "app/internal/app/model"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"gorm.io/gorm"
+)
+
+const (
+	// DefaultTaskPageSize 任务列表每页默认条数
+	DefaultTaskPageSize = 20
+	// MaxTaskPageSize 任务列表每页最多条数
+	MaxTaskPageSize = 100
+	// DefaultTaskSort 默认按创建时间倒序
+	DefaultTaskSort = "-created_at"
+	// MaxTaskTags 每个任务最多的标签数
+	MaxTaskTags = 10
+	// MaxTagLength 标签最大字数
+	MaxTagLength = 32
+)
+
+var (
+	ErrInvalidTaskSort = errors.New("sort must be one of created_at, updated_at, due_at, points, title, optionally prefixed with -")
+	ErrInvalidCursor   = errors.New("invalid cursor")
+)
+
+// taskSortColumns 允许排序的字段
+var taskSortColumns = map[string]string{
+	"created_at": "created_at",
+	"updated_at": "updated_at",
+	"due_at":     "due_at",
+	"points":     "points",
+	"title":      "title",
+}
+
+// TaskQuery 任务列表的筛选、排序和分页参数，零值表示不限
+type TaskQuery struct {
+	Completed     *bool
+	TaskType      string
+	Category      string
+	CreatedAfter  *time.Time // 包含
+	CreatedBefore *time.Time // 不包含
+	DueAfter      *time.Time // 包含
+	DueBefore     *time.Time // 不包含
+	MinPoints     *int
+	MaxPoints     *int
+	Tag           string
+	Sort          string // 字段名，前缀 - 表示倒序，默认 DefaultTaskSort；按 due_at 排序时只列出有截止时间的任务
+	Cursor        string // 上一页返回的 next_cursor
+	Limit         int
+}
+
+// TaskPage 一页任务，NextCursor 为空表示没有下一页；
+// Total 和 CompletedTotal 是符合筛选条件的任务总数和其中已完成的数量
+type TaskPage struct {
+	Tasks          []models.Task `json:"tasks"`
+	NextCursor     string        `json:"next_cursor"`
+	Total          int64         `json:"total"`
+	CompletedTotal int64         `json:"completed_total"`
+}
+
+// taskCursor 记录上一页最后一条任务的排序字段值和 ID
+type taskCursor struct {
+	Sort  string `json:"s"`
+	Value string `json:"v"`
+	ID    uint   `json:"id"`
+}
+
+// listTasks 按 scope 限定的范围查询一页任务。使用游标分页，
+// 排序字段相同时按 ID 排序，翻页过程中插入新任务不会造成重复或遗漏
+func (s *TaskService) listTasks(scope func(*gorm.DB) *gorm.DB, query TaskQuery) (*TaskPage, error) {
+	sort := query.Sort
+	if sort == "" {
+		sort = DefaultTaskSort
+	}
+	desc := strings.HasPrefix(sort, "-")
+	column, ok := taskSortColumns[strings.TrimPrefix(sort, "-")]
+	if !ok {
+		return nil, ErrInvalidTaskSort
+	}
+	limit := query.Limit
+	if limit < 1 {
+		limit = DefaultTaskPageSize
+	}
+	if limit > MaxTaskPageSize {
+		limit = MaxTaskPageSize
+	}
+
+	var cursor *taskCursor
+	var cursorValue interface{}
+	if query.Cursor != "" {
+		var err error
+		if cursor, cursorValue, err = decodeTaskCursor(query.Cursor, sort, column); err != nil {
+			return nil, err
+		}
+	}
+
+	filtered := func() *gorm.DB {
+		db := applyTaskFilters(scope(s.db.Model(&models.Task{})), query)
+		if column == "due_at" {
+			// 游标无法比较空值
+			db = db.Where("due_at IS NOT NULL")
+		}
+		return db
+	}
+
+	page := &TaskPage{Tasks: []models.Task{}}
+	if err := filtered().Count(&page.Total).Error; err != nil {
+		return nil, err
+	}
+	if err := filtered().Where("completed = ?", true).Count(&page.CompletedTotal).Error; err != nil {
+		return nil, err
+	}
+
+	direction, op := "ASC", ">"
+	if desc {
+		direction, op = "DESC", "<"
+	}
+	db := filtered()
+	if cursor != nil {
+		db = db.Where(fmt.Sprintf("%s %s ? OR (%s = ? AND id %s ?)", column, op, column, op),
+			cursorValue, cursorValue, cursor.ID)
+	}
+
+	var tasks []models.Task
+	if err := db.Order(column + " " + direction).Order("id " + direction).
+		Limit(limit + 1).Find(&tasks).Error; err != nil {
+		return nil, err
+	}
+	if len(tasks) > limit {
+		tasks = tasks[:limit]
+		page.NextCursor = encodeTaskCursor(sort, column, &tasks[limit-1])
+	}
+
+	ptrs := make([]*models.Task, len(tasks))
+	for i := range tasks {
+		ptrs[i] = &tasks[i]
+	}
+	if err := loadTaskDetails(s.db, ptrs); err != nil {
+		return nil, err
+	}
+	page.Tasks = append(page.Tasks, tasks...)
+	return page, nil
+}
+
+// applyTaskFilters 添加筛选条件
+func applyTaskFilters(db *gorm.DB, query TaskQuery) *gorm.DB {
+	if query.Completed != nil {
+		db = db.Where("completed = ?", *query.Completed)
+	}
+	if query.TaskType != "" {
+		db = db.Where("task_type = ?", query.TaskType)
+	}
+	if query.Category == TrackTodo {
+		// 分类为空的旧任务视为 todo
+		db = db.Where("COALESCE(category, '') IN ?", []string{TrackTodo, ""})
+	} else if query.Category != "" {
+		db = db.Where("category = ?", query.Category)
+	}
+	if query.CreatedAfter != nil {
+		db = db.Where("created_at >= ?", *query.CreatedAfter)
+	}
+	if query.CreatedBefore != nil {
+		db = db.Where("created_at < ?", *query.CreatedBefore)
+	}
+	if query.DueAfter != nil {
+		db = db.Where("due_at >= ?", *query.DueAfter)
+	}
+	if query.DueBefore != nil {
+		db = db.Where("due_at < ?", *query.DueBefore)
+	}
+	if query.MinPoints != nil {
+		db = db.Where("points >= ?", *query.MinPoints)
+	}
+	if query.MaxPoints != nil {
+		db = db.Where("points <= ?", *query.MaxPoints)
+	}
+	if tag := normalizeTag(query.Tag); tag != "" {
+		db = db.Where("id IN (?)", db.Session(&gorm.Session{NewDB: true}).
+			Model(&models.TaskTag{}).Select("task_id").Where("tag = ?", tag))
+	}
+	return db
+}
+
+// encodeTaskCursor 用本页最后一条任务生成下一页的游标
+func encodeTaskCursor(sort, column string, task *models.Task) string {
+	cursor := taskCursor{Sort: sort, ID: task.ID}
+	switch column {
+	case "created_at":
+		cursor.Value = task.CreatedAt.Format(time.RFC3339Nano)
+	case "updated_at":
+		cursor.Value = task.UpdatedAt.Format(time.RFC3339Nano)
+	case "due_at":
+		cursor.Value = task.DueAt.Format(time.RFC3339Nano)
+	case "points":
+		cursor.Value = strconv.Itoa(task.Points)
+	default:
+		cursor.Value = task.Title
+	}
+	data, _ := json.Marshal(cursor)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// decodeTaskCursor 解析游标，游标必须来自相同排序方式的上一页
+func decodeTaskCursor(encoded, sort, column string) (*taskCursor, interface{}, error) {
+	data, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, nil, ErrInvalidCursor
+	}
+	var cursor taskCursor
+	if err := json.Unmarshal(data, &cursor); err != nil || cursor.Sort != sort {
+		return nil, nil, ErrInvalidCursor
+	}
+
+	switch column {
+	case "created_at", "updated_at", "due_at":
+		value, err := time.Parse(time.RFC3339Nano, cursor.Value)
+		if err != nil {
+			return nil, nil, ErrInvalidCursor
+		}
+		return &cursor, value, nil
+	case "points":
+		value, err := strconv.Atoi(cursor.Value)
+		if err != nil {
+			return nil, nil, ErrInvalidCursor
+		}
+		return &cursor, value, nil
+	default:
+		return &cursor, cursor.Value, nil
+	}
+}
+
+// normalizeTag 标签不区分大小写，统一保存为小写
+func normalizeTag(tag string) string {
+	return strings.ToLower(strings.TrimSpace(tag))
+}
+
+// normalizeTags 去掉空白和重复的标签，并检查数量和长度
+func normalizeTags(tags []string) ([]string, error) {
+	result := make([]string, 0, len(tags))
+	seen := make(map[string]bool)
+	for _, tag := range tags {
+		tag = normalizeTag(tag)
+		if tag == "" || seen[tag] {
+			continue
+		}
+		if utf8.RuneCountInString(tag) > MaxTagLength {
+			return nil, ErrInvalidTags
+		}
+		seen[tag] = true
+		result = append(result, tag)
+	}
+	if len(result) > MaxTaskTags {
+		return nil, ErrInvalidTags
+	}
+	return result, nil
+}
+
+// setTaskTags 用 tags 替换任务现有的标签
+func setTaskTags(tx *gorm.DB, taskID uint, tags []string) error {
+	if err := tx.Where("task_id = ?", taskID).Delete(&models.TaskTag{}).Error; err != nil {
+		return err
+	}
+	if len(tags) == 0 {
+		return nil
+	}
+	rows := make([]models.TaskTag, len(tags))
+	for i, tag := range tags {
+		rows[i] = models.TaskTag{TaskID: taskID, Tag: tag}
+	}
+	return tx.Create(&rows).Error
+}
+
+// loadTaskDetails 批量读取任务的标签，并计算是否逾期
+func loadTaskDetails(db *gorm.DB, tasks []*models.Task) error {
+	if len(tasks) == 0 {
+		return nil
+	}
+	now := time.Now()
+	byID := make(map[uint]*models.Task, len(tasks))
+	ids := make([]uint, 0, len(tasks))
+	for _, task := range tasks {
+		task.Tags = []string{}
+		task.Overdue = taskOverdue(task, now)
+		byID[task.ID] = task
+		ids = append(ids, task.ID)
+	}
+
+	var rows []models.TaskTag
+	if err := db.Where("task_id IN ?", ids).Order("id").Find(&rows).Error; err != nil {
+		return err
+	}
+	for _, row := range rows {
+		if task, ok := byID[row.TaskID]; ok {
+			task.Tags = append(task.Tags, row.Tag)
+		}
+	}
+	return nil
+}
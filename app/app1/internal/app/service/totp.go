@@ -0,0 +1,313 @@
+package services
+
+import (
+	models "app/internal/app/model"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	// TOTPIssuer 显示在验证器应用中的服务名称
+	TOTPIssuer = "Guide"
+	// totpPeriod、totpDigits 与 Google Authenticator 等常见验证器的默认值一致
+	totpPeriod = 30
+	totpDigits = 6
+	// totpSkew 允许前后各一个时间步的时钟误差
+	totpSkew = 1
+	// RecoveryCodeCount 开启两步验证时生成的恢复码数量
+	RecoveryCodeCount = 10
+	// MFAChallengeTTL 密码验证通过后完成第二步验证的时限
+	MFAChallengeTTL = 5 * time.Minute
+	// maxMFAAttempts 同一次登录最多允许输错的次数
+	maxMFAAttempts = 5
+)
+
+var (
+	ErrTOTPAlreadyEnabled = errors.New("two-factor authentication is already enabled")
+	ErrTOTPNotEnabled     = errors.New("two-factor authentication is not enabled")
+	ErrTOTPNotPending     = errors.New("start two-factor enrollment first")
+	ErrInvalidTOTP        = errors.New("invalid two-factor code")
+	ErrInvalidMFAToken    = errors.New("invalid or expired two-factor login, please login again")
+)
+
+// IsTOTPError 判断是否为两步验证相关的客户端错误
+func IsTOTPError(err error) bool {
+	for _, target := range []error{ErrTOTPAlreadyEnabled, ErrTOTPNotEnabled, ErrTOTPNotPending, ErrInvalidTOTP} {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// totpCode 按 RFC 6238 计算指定时间步的验证码
+func totpCode(secret []byte, counter int64) string {
+	var msg [8]byte
+	binary.BigEndian.PutUint64(msg[:], uint64(counter))
+	mac := hmac.New(sha1.New, secret)
+	mac.Write(msg[:])
+	sum := mac.Sum(nil)
+
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+	mod := uint32(1)
+	for i := 0; i < totpDigits; i++ {
+		mod *= 10
+	}
+	return fmt.Sprintf("%0*d", totpDigits, value%mod)
+}
+
+// validateTOTP 校验验证码，返回匹配的时间步；时间步不大于 lastCounter 的验证码视为重放
+func validateTOTP(secret, code string, lastCounter int64, now time.Time) (int64, bool) {
+	key, err := base32.StdEncoding.WithPadding(base32.NoPadding).DecodeString(strings.ToUpper(secret))
+	if err != nil {
+		return 0, false
+	}
+	current := now.Unix() / totpPeriod
+	for counter := current - totpSkew; counter <= current+totpSkew; counter++ {
+		if counter <= lastCounter {
+			continue
+		}
+		if subtle.ConstantTimeCompare([]byte(totpCode(key, counter)), []byte(code)) == 1 {
+			return counter, true
+		}
+	}
+	return 0, false
+}
+
+// generateTOTPSecret 生成 160 位随机密钥
+func generateTOTPSecret() string {
+	b := make([]byte, 20)
+	rand.Read(b)
+	return base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(b)
+}
+
+// generateRecoveryCode 生成 xxxxx-xxxxx 形式的恢复码
+func generateRecoveryCode() string {
+	const charset = "abcdefghijkmnpqrstuvwxyz23456789"
+	b := make([]byte, 10)
+	rand.Read(b)
+	for i := range b {
+		b[i] = charset[b[i]%byte(len(charset))]
+	}
+	return string(b[:5]) + "-" + string(b[5:])
+}
+
+// normalizeRecoveryCode 忽略大小写、空格和连字符
+func normalizeRecoveryCode(code string) string {
+	code = strings.ToLower(strings.TrimSpace(code))
+	return strings.ReplaceAll(code, "-", "")
+}
+
+// BeginTOTPEnrollment 生成新的密钥并返回 otpauth:// 地址，确认之前不会生效
+func (s *AuthService) BeginTOTPEnrollment(userID uint) (secret string, uri string, err error) {
+	user, err := s.GetUser(userID)
+	if err != nil {
+		return "", "", err
+	}
+	if user.TOTPEnabled {
+		return "", "", ErrTOTPAlreadyEnabled
+	}
+
+	secret = generateTOTPSecret()
+	if err := s.db.Model(user).Update("totp_pending_secret", secret).Error; err != nil {
+		return "", "", err
+	}
+
+	label := url.PathEscape(TOTPIssuer + ":" + user.Email)
+	query := url.Values{}
+	query.Set("secret", secret)
+	query.Set("issuer", TOTPIssuer)
+	query.Set("period", fmt.Sprint(totpPeriod))
+	query.Set("digits", fmt.Sprint(totpDigits))
+	return secret, "otpauth://totp/" + label + "?" + query.Encode(), nil
+}
+
+// ConfirmTOTPEnrollment 用验证器生成的第一个验证码确认绑定，返回只显示一次的恢复码
+func (s *AuthService) ConfirmTOTPEnrollment(userID uint, code string) ([]string, error) {
+	user, err := s.GetUser(userID)
+	if err != nil {
+		return nil, err
+	}
+	if user.TOTPEnabled {
+		return nil, ErrTOTPAlreadyEnabled
+	}
+	if user.TOTPPendingSecret == "" {
+		return nil, ErrTOTPNotPending
+	}
+	counter, ok := validateTOTP(user.TOTPPendingSecret, strings.TrimSpace(code), 0, time.Now())
+	if !ok {
+		return nil, ErrInvalidTOTP
+	}
+
+	codes := make([]string, RecoveryCodeCount)
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(user).Updates(map[string]interface{}{
+			"totp_enabled":        true,
+			"totp_secret":         user.TOTPPendingSecret,
+			"totp_pending_secret": "",
+			"totp_last_counter":   counter,
+		}).Error; err != nil {
+			return err
+		}
+		if err := tx.Unscoped().Where("user_id = ?", userID).Delete(&models.RecoveryCode{}).Error; err != nil {
+			return err
+		}
+		for i := range codes {
+			codes[i] = generateRecoveryCode()
+			if err := tx.Create(&models.RecoveryCode{UserID: userID, CodeHash: hashToken(normalizeRecoveryCode(codes[i]))}).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// DisableTOTP 校验验证器验证码、恢复码或密码后关闭两步验证并删除恢复码；
+// 只通过第三方登录注册的用户没有密码，需要提供验证码或恢复码
+func (s *AuthService) DisableTOTP(userID uint, password, code string) error {
+	user, err := s.GetUser(userID)
+	if err != nil {
+		return err
+	}
+	if !user.TOTPEnabled {
+		return ErrTOTPNotEnabled
+	}
+	if strings.TrimSpace(code) != "" {
+		// 和登录第二步共用失败锁定，避免借此猜测验证码
+		lockKey := fmt.Sprintf("mfa:%d", userID)
+		if err := s.limits.checkLocked(lockKey); err != nil {
+			return err
+		}
+		ok, err := s.checkSecondFactor(user, code)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			s.limits.Failures.Fail(lockKey)
+			return ErrInvalidTOTP
+		}
+		s.limits.Failures.Succeed(lockKey)
+	} else if user.Password == "" {
+		return ErrInvalidTOTP
+	} else if ok, _ := verifyPassword(password, user.Password); !ok {
+		return ErrWrongPassword
+	}
+
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(user).Updates(map[string]interface{}{
+			"totp_enabled":        false,
+			"totp_secret":         "",
+			"totp_pending_secret": "",
+			"totp_last_counter":   0,
+		}).Error; err != nil {
+			return err
+		}
+		return tx.Unscoped().Where("user_id = ?", userID).Delete(&models.RecoveryCode{}).Error
+	})
+}
+
+// CreateMFAChallenge 第一步验证通过后生成登录凭证，客户端用它和验证码完成登录
+func (s *AuthService) CreateMFAChallenge(userID uint) (string, error) {
+	token := generateToken()
+	challenge := models.MFAChallenge{
+		UserID:    userID,
+		TokenHash: hashToken(token),
+		ExpiresAt: time.Now().Add(MFAChallengeTTL),
+	}
+	if err := s.db.Create(&challenge).Error; err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// CompleteMFAChallenge 校验验证器验证码或恢复码，成功后登录凭证作废
+func (s *AuthService) CompleteMFAChallenge(token, code string) (*models.User, error) {
+	var challenge models.MFAChallenge
+	if err := s.db.Where("token_hash = ?", hashToken(token)).First(&challenge).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrInvalidMFAToken
+		}
+		return nil, err
+	}
+	if time.Now().After(challenge.ExpiresAt) {
+		s.db.Unscoped().Delete(&challenge)
+		return nil, ErrInvalidMFAToken
+	}
+
+	// 同一账号连续输错时暂时锁定，避免换新的登录凭证继续猜测
+	lockKey := fmt.Sprintf("mfa:%d", challenge.UserID)
+	if err := s.limits.checkLocked(lockKey); err != nil {
+		return nil, err
+	}
+
+	user, err := s.GetUser(challenge.UserID)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkUserActive(user); err != nil {
+		return nil, err
+	}
+
+	ok, err := s.checkSecondFactor(user, code)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		s.limits.Failures.Fail(lockKey)
+		challenge.Attempts++
+		if challenge.Attempts >= maxMFAAttempts {
+			s.db.Unscoped().Delete(&challenge)
+			return nil, ErrInvalidMFAToken
+		}
+		if err := s.db.Model(&challenge).Update("attempts", challenge.Attempts).Error; err != nil {
+			return nil, err
+		}
+		return nil, ErrInvalidTOTP
+	}
+
+	s.limits.Failures.Succeed(lockKey)
+	if err := s.db.Unscoped().Delete(&challenge).Error; err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// checkSecondFactor 依次尝试验证器验证码和未使用的恢复码
+func (s *AuthService) checkSecondFactor(user *models.User, code string) (bool, error) {
+	code = strings.TrimSpace(code)
+	if counter, ok := validateTOTP(user.TOTPSecret, code, user.TOTPLastCounter, time.Now()); ok {
+		// 只有计数仍小于本次时间步时才更新，同时提交的同一个验证码只有一个请求能通过
+		result := s.db.Model(&models.User{}).Where("id = ? AND totp_last_counter < ?", user.ID, counter).
+			Update("totp_last_counter", counter)
+		if result.Error != nil {
+			return false, result.Error
+		}
+		user.TOTPLastCounter = counter
+		return result.RowsAffected > 0, nil
+	}
+
+	now := time.Now()
+	result := s.db.Model(&models.RecoveryCode{}).
+		Where("user_id = ? AND code_hash = ? AND used_at IS NULL", user.ID, hashToken(normalizeRecoveryCode(code))).
+		Update("used_at", &now)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected > 0, nil
+}
@@ -2,15 +2,158 @@ package services
 
 import (
 	models "app/internal/app/model"
+	"errors"
+	"log"
+	"time"
+
 	"gorm.io/gorm"
 )
 
+// ErrInvalidCredentials 用户名或密码错误
+var ErrInvalidCredentials = errors.New("Invalid username or password")
+
 type AuthService struct {
-	db *gorm.DB
+	db        *gorm.DB
+	codes     VerificationStore
+	mailer    Mailer
+	templates *MailTemplates
+	limits    *AuthLimits
+
+	oidcProviders []*OIDCProvider
+}
+
+func NewAuthService(db *gorm.DB, codes VerificationStore, mailer Mailer, templates *MailTemplates, limits *AuthLimits, oidcProviders []*OIDCProvider) *AuthService {
+	return &AuthService{db: db, codes: codes, mailer: mailer, templates: templates, limits: limits, oidcProviders: oidcProviders}
+}
+
+// LoginUser 使用用户名或邮箱以及密码登录，校验成功返回用户
+func (s *AuthService) LoginUser(account, password, ip string) (*models.User, error) {
+	// 同一个 IP 连续输错某个账号的密码时暂时锁定，只影响这个 IP，
+	// 其他人输错密码不会把账号本人锁在外面
+	lockKey := "password:" + ip + ":" + account
+	if err := s.limits.checkLocked(lockKey); err != nil {
+		return nil, err
+	}
+
+	// 查找用户
+	var user models.User
+	if err := s.db.Where("username = ? OR email = ?", account, account).First(&user).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			s.limits.Failures.Fail(lockKey)
+			return nil, ErrInvalidCredentials
+		}
+		return nil, err
+	}
+
+	// 验证密码
+	ok, needsRehash := verifyPassword(password, user.Password)
+	if !ok {
+		s.limits.Failures.Fail(lockKey)
+		return nil, ErrInvalidCredentials
+	}
+	s.limits.Failures.Succeed(lockKey)
+	if err := checkUserActive(&user); err != nil {
+		return nil, err
+	}
+
+	// 旧的 SHA-256 哈希或过时参数的哈希在登录成功后就地升级
+	if needsRehash {
+		hashed, err := hashPassword(password)
+		if err != nil {
+			return nil, err
+		}
+		if err := s.db.Model(&user).Update("password", hashed).Error; err != nil {
+			return nil, err
+		}
+	}
+
+	return &user, nil
+}
+
+// LoginWithCode 使用邮箱验证码登录
+func (s *AuthService) LoginWithCode(email, code string) (*models.User, error) {
+	if err := s.VerifyCode(PurposeLogin, email, code); err != nil {
+		return nil, err
+	}
+
+	var user models.User
+	if err := s.db.Where("email = ?", email).First(&user).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrInvalidCredentials
+		}
+		return nil, err
+	}
+	if err := checkUserActive(&user); err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// SendVerificationCode 生成指定用途的6位数字验证码，保存后通过邮件发送
+func (s *AuthService) SendVerificationCode(purpose CodePurpose, email string) error {
+	if err := s.limits.checkSendLimits(purpose, email); err != nil {
+		return err
+	}
+	return s.issueCode(purpose, email, VerificationCodeTTL)
+}
+
+// issueCode 生成、保存并发送验证码
+func (s *AuthService) issueCode(purpose CodePurpose, email string, ttl time.Duration) error {
+	return s.issueCodeTo(purpose, email, email, s.localeForEmail(email), ttl)
 }
 
-func NewAuthService(db *gorm.DB) *AuthService {
-	return &AuthService{db: db}
+// issueCodeTo 验证码按 key 保存，用 locale 渲染后发送到 email；
+// 修改邮箱时 key 中包含用户ID，验证码只对发起请求的用户有效
+func (s *AuthService) issueCodeTo(purpose CodePurpose, key, email, locale string, ttl time.Duration) error {
+	verificationCode := generateRandomNumericCode(6)
+	if err := s.codes.Save(purpose, key, verificationCode, ttl); err != nil {
+		return err
+	}
+
+	name := MailVerification
+	if purpose == PurposeReset {
+		name = MailPasswordReset
+	}
+	return s.sendMail(name, locale, email, map[string]interface{}{
+		"Code":    verificationCode,
+		"Purpose": string(purpose),
+		"Minutes": int(ttl.Minutes()),
+	})
+}
+
+// localeForEmail 已注册邮箱使用用户的语言偏好，注册阶段使用默认语言
+func (s *AuthService) localeForEmail(email string) string {
+	var user models.User
+	if err := s.db.Select("locale").Where("email = ?", email).First(&user).Error; err != nil {
+		return DefaultLocale
+	}
+	return user.Locale
+}
+
+// sendMail 按用户语言渲染模板并发送
+func (s *AuthService) sendMail(name, locale, to string, data interface{}) error {
+	msg, err := s.templates.Render(name, locale, to, data)
+	if err != nil {
+		return err
+	}
+	return s.mailer.Send(msg)
+}
+
+// VerifyCode 校验并消费验证码，同一邮箱连续输错过多时暂时锁定
+func (s *AuthService) VerifyCode(purpose CodePurpose, email, code string) error {
+	lockKey := "code:" + email
+	if err := s.limits.checkLocked(lockKey); err != nil {
+		return err
+	}
+
+	err := s.codes.Verify(purpose, email, code)
+	switch {
+	case err == nil:
+		s.limits.Failures.Succeed(lockKey)
+	case errors.Is(err, ErrCodeMismatch) || errors.Is(err, ErrTooManyAttempts):
+		s.limits.Failures.Fail(lockKey)
+	}
+	return err
 }
 
 func (s *AuthService) UpgradeUser(userID uint) error {
@@ -38,43 +181,50 @@ func (s *AuthService) UpgradeUser(userID uint) error {
 		if err := s.db.Save(&user).Error; err != nil {
 			return err
 		}
+
+		// 升级已经生效，邮件发送失败只记录日志
+		if err := s.sendMail(MailLevelUp, user.Locale, user.Email, map[string]interface{}{
+			"Username": user.Username,
+			"Level":    user.Level,
+		}); err != nil {
+			log.Printf("send level-up mail to user %d: %v", user.ID, err)
+		}
 	}
 
 	return nil
 }
 
-func (s *AuthService) GetDailyTask() ([]models.Task, error) {
-	// 在此处编写逻辑以获取每日任务
-	// 例如，从数据库中查询所有每日任务并返回
-
-	var dailyTasks []models.Task
-	if err := s.db.Where("is_daily = ?", true).Find(&dailyTasks).Error; err != nil {
+// GetDailyTask 列出用户仍在生成实例的周期任务
+func (s *AuthService) GetDailyTask(userID uint) ([]models.RecurringTask, error) {
+	var dailyTasks []models.RecurringTask
+	if err := s.db.Where("user_id = ? AND active = ?", userID, true).Order("id").Find(&dailyTasks).Error; err != nil {
 		return nil, err
 	}
 
 	return dailyTasks, nil
 }
+
+// GetDailyTaskByUserID 返回周期任务在用户时区今天生成的实例
 func (s *AuthService) GetDailyTaskByUserID(userID uint) ([]models.Task, error) {
-	// 在数据库中查询特定用户的每日任务并返回
-	var dailyTasks []models.Task
-	if err := s.db.Where("user_id = ? AND is_daily = ?", userID, true).Find(&dailyTasks).Error; err != nil {
+	user, err := s.GetUser(userID)
+	if err != nil {
 		return nil, err
 	}
-
-	return dailyTasks, nil
+	today := civilDate(time.Now(), UserLocation(user)).Format(DateLayout)
+	return recurringInstances(s.db, userID, today)
 }
 
+// ConvertPointsToExperience 把可用积分按 1:1 转换为总经验，积分不足时返回 ErrInsufficientPoints
 func (s *AuthService) ConvertPointsToExperience(userID uint, points int) error {
-	// 根据任务积分计算经验值并更新用户经验值
-	var user models.User
-	if err := s.db.First(&user, userID).Error; err != nil {
-		return err
+	if points < 1 {
+		return ErrInvalidQuantity
 	}
-	user.Experience += points
-	if err := s.db.Save(&user).Error; err != nil {
-		return err
-	}
-	return nil
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		if err := spendPoints(tx, userID, points, ExperienceSourceConvert, "converted points to experience"); err != nil {
+			return err
+		}
+		return addExperience(tx, userID, TrackExperience, points, ExperienceSourceConvert, "converted points to experience")
+	})
 }
 
 func (s *AuthService) GetUserExperienceAndLevel(userID uint64) (*models.User, error) {
@@ -0,0 +1,185 @@
+package services
+
+import (
+	models "app/internal/app/model"
+	"crypto/subtle"
+	"errors"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// CodePurpose 验证码用途，不同用途的验证码互不通用
+type CodePurpose string
+
+const (
+	PurposeRegister    CodePurpose = "register"
+	PurposeLogin       CodePurpose = "login"
+	PurposeReset       CodePurpose = "reset"
+	PurposeChangeEmail CodePurpose = "change_email" // 修改邮箱时发送到新邮箱的验证码
+)
+
+const (
+	// VerificationCodeTTL 验证码有效期
+	VerificationCodeTTL = 10 * time.Minute
+	// MaxVerificationAttempts 同一个验证码最多允许输错的次数，超过后验证码作废
+	MaxVerificationAttempts = 5
+)
+
+var (
+	ErrCodeNotFound    = errors.New("verification code not found")
+	ErrCodeExpired     = errors.New("verification code expired")
+	ErrCodeMismatch    = errors.New("invalid verification code")
+	ErrTooManyAttempts = errors.New("too many failed attempts, please request a new code")
+)
+
+// VerificationStore 验证码存储，Verify 成功后验证码即被消费，不能重复使用
+type VerificationStore interface {
+	// Save 保存验证码，同一邮箱同一用途之前的验证码随之失效
+	Save(purpose CodePurpose, email, code string, ttl time.Duration) error
+	// Verify 校验验证码，输错会累计次数
+	Verify(purpose CodePurpose, email, code string) error
+}
+
+// checkCode 比较验证码，供各个存储实现共用
+func checkCode(expected, code string) bool {
+	return subtle.ConstantTimeCompare([]byte(expected), []byte(hashToken(code))) == 1
+}
+
+type memoryCode struct {
+	hash      string
+	expiresAt time.Time
+	attempts  int
+}
+
+// MemoryVerificationStore 进程内的验证码存储，适合单实例部署和本地开发
+type MemoryVerificationStore struct {
+	mu          sync.Mutex
+	codes       map[string]*memoryCode
+	maxAttempts int
+}
+
+// NewMemoryVerificationStore 创建进程内验证码存储
+func NewMemoryVerificationStore(maxAttempts int) *MemoryVerificationStore {
+	return &MemoryVerificationStore{codes: make(map[string]*memoryCode), maxAttempts: maxAttempts}
+}
+
+func memoryKey(purpose CodePurpose, email string) string {
+	return string(purpose) + ":" + email
+}
+
+func (s *MemoryVerificationStore) Save(purpose CodePurpose, email, code string, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// 顺便清理已过期的验证码，避免 map 无限增长
+	now := time.Now()
+	for key, entry := range s.codes {
+		if now.After(entry.expiresAt) {
+			delete(s.codes, key)
+		}
+	}
+
+	s.codes[memoryKey(purpose, email)] = &memoryCode{hash: hashToken(code), expiresAt: now.Add(ttl)}
+	return nil
+}
+
+func (s *MemoryVerificationStore) Verify(purpose CodePurpose, email, code string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := memoryKey(purpose, email)
+	entry, ok := s.codes[key]
+	if !ok {
+		return ErrCodeNotFound
+	}
+	if time.Now().After(entry.expiresAt) {
+		delete(s.codes, key)
+		return ErrCodeExpired
+	}
+	if !checkCode(entry.hash, code) {
+		entry.attempts++
+		if entry.attempts >= s.maxAttempts {
+			delete(s.codes, key)
+			return ErrTooManyAttempts
+		}
+		return ErrCodeMismatch
+	}
+
+	delete(s.codes, key)
+	return nil
+}
+
+// DBVerificationStore 基于数据库的验证码存储，多实例部署时共享验证码
+type DBVerificationStore struct {
+	db          *gorm.DB
+	maxAttempts int
+}
+
+// NewDBVerificationStore 创建数据库验证码存储
+func NewDBVerificationStore(db *gorm.DB, maxAttempts int) *DBVerificationStore {
+	return &DBVerificationStore{db: db, maxAttempts: maxAttempts}
+}
+
+func (s *DBVerificationStore) Save(purpose CodePurpose, email, code string, ttl time.Duration) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		// 之前的验证码直接删除
+		if err := tx.Unscoped().Where("email = ? AND purpose = ?", email, string(purpose)).
+			Delete(&models.VerificationCode{}).Error; err != nil {
+			return err
+		}
+		return tx.Create(&models.VerificationCode{
+			Email:     email,
+			Purpose:   string(purpose),
+			CodeHash:  hashToken(code),
+			ExpiresAt: time.Now().Add(ttl),
+		}).Error
+	})
+}
+
+func (s *DBVerificationStore) Verify(purpose CodePurpose, email, code string) error {
+	var result error
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		// 加行锁，防止并发请求重复使用同一个验证码
+		var record models.VerificationCode
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("email = ? AND purpose = ? AND consumed_at IS NULL", email, string(purpose)).
+			Order("id DESC").First(&record).Error
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				result = ErrCodeNotFound
+				return nil
+			}
+			return err
+		}
+
+		now := time.Now()
+		if now.After(record.ExpiresAt) {
+			result = ErrCodeExpired
+			return tx.Delete(&record).Error
+		}
+		if !checkCode(record.CodeHash, code) {
+			record.Attempts++
+			if record.Attempts >= s.maxAttempts {
+				result = ErrTooManyAttempts
+				return tx.Delete(&record).Error
+			}
+			result = ErrCodeMismatch
+			return tx.Model(&record).Update("attempts", record.Attempts).Error
+		}
+
+		return tx.Model(&record).Update("consumed_at", &now).Error
+	})
+	if err != nil {
+		return err
+	}
+	return result
+}
+
+// IsVerificationError 判断是否为验证码本身的错误（不存在、过期、错误、次数过多）
+func IsVerificationError(err error) bool {
+	return errors.Is(err, ErrCodeNotFound) || errors.Is(err, ErrCodeExpired) ||
+		errors.Is(err, ErrCodeMismatch) || errors.Is(err, ErrTooManyAttempts)
+}